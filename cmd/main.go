@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/pterm/pterm"
 	"log"
 	"os"
+	"os/signal"
 	"port-audit/internal"
-	"sync"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -34,10 +39,15 @@ func main() {
 	// FROM THIS POINT ON, ALL LOG MESSAGES WILL BE WRITTEN TO THE FILE
 
 	// Setup and parse command-line arguments
-	username, password, filePath, baseFile, generateInv, usageGuide, err := internal.SetupFlags()
+	cfg, err := internal.SetupFlags()
+
+	// Keep the screen output free of colour codes when requested (e.g. cron and CI logs)
+	if cfg.NoColor {
+		pterm.DisableColor()
+	}
 
 	// Check if the usage flag is set and display the usage guide
-	if *usageGuide {
+	if cfg.UsageGuide {
 		internal.PrintUsageGuide(internal.CiscoPortAuditUsageGuide)
 		logger.Info("Displaying usage guide only, exiting the program.")
 		log.Printf("Displaying usage guide only, exiting the program: %v", err)
@@ -45,14 +55,14 @@ func main() {
 	}
 
 	// Check if the generate inventory flag is set and a file path is provided
-	if *generateInv {
-		if *filePath == "" {
+	if cfg.GenerateInv {
+		if cfg.InventoryFile == "" {
 			logger.Fatal("File path is required for generating inventory.")
 			log.Printf("File path is required for generating inventory.")
 			os.Exit(1)
 		}
 		// Call function to generate inventory file
-		internal.GenerateInventory(*filePath, logger)
+		internal.GenerateInventory(cfg.InventoryFile, logger)
 		return
 	}
 
@@ -63,124 +73,86 @@ func main() {
 		os.Exit(1)
 	}
 
-	if *username == "" {
-		err = fmt.Errorf("username is required")
-		logger.Fatal("Exiting the program due to setup failure", logger.Args("Reason", err)) // Log to the screen
-		log.Printf("Exiting the program due to setup failure: %v", err)                      // Log to the filePath
-		os.Exit(1)
-	}
+	// The run ID names the audit sheet of this run, so several audits a day do not collide
+	cfg.RunID = internal.NewRunID(startTime)
+	log.Printf("Run ID: %s", cfg.RunID)
 
-	if *password == "" {
-		err = fmt.Errorf("password is required")
-		logger.Fatal("Exiting the program due to setup failure", logger.Args("Reason", err)) // Log to the screen
-		log.Printf("Exiting the program due to setup failure: %v", err)                      // Log to the filePath
-		os.Exit(1)
+	if cfg.TemplateDir != "" {
+		logger.Info("Parsing with the TextFSM templates of the directory before the built-in parsers.", logger.Args("Templates", cfg.TemplateDir))
 	}
 
-	logger.Trace("Successfully passed the parameters for setup.") // log to the screen
-	log.Printf("Successfully passed the parameters for setup")    // Log to the filePath
-
-	// Setup menu
-	// Define command options and their corresponding SSH commands
-	options := []string{"NXOS/IOS - show interface status", "IOS - show interface description", "IOSXR - show interface description"}
-	commands := map[string]string{
-		"NXOS/IOS - show interface status":   "show interface status",
-		"IOS - show interface description":   "show interface description",
-		"IOSXR - show interface description": "show int description",
+	var results []internal.DeviceResult
+	var cleartextDevices []string
+	if cfg.ReplayDir != "" {
+		// Offline replay: parse previously captured output, no device is contacted
+		results = replayDevices(cfg, logger)
+	} else {
+		results, cleartextDevices = collectDevices(cfg, logger)
 	}
 
-	// Interactive menu to select a command
-	printer := pterm.DefaultInteractiveSelect.WithOptions(options)
-	selectedOption, _ := printer.Show()
-
-	selectedCommand, exists := commands[selectedOption]
-	if !exists {
-		logger.Fatal("No valid command selected")
-		log.Printf("No valid command selected: %v", err) // Log to the filePath
-		os.Exit(1)
+	// Outcome of every device, reported in the summary and the results file, and the rows of the audit sheet,
+	// where devices without interface data are marked as not collected
+	statuses := make([]internal.DeviceStatus, 0, len(results))
+	allData := make([]internal.InterfaceData, 0)
+	collected := 0
+	for _, result := range results {
+		statuses = append(statuses, result.Status)
+		allData = append(allData, result.ReportRows()...)
+		collected += len(result.Rows)
 	}
-	logger.Info("Selected command:", logger.Args("Command", pterm.Green(selectedCommand)))
 
-	// Read the inventory file
-	inventory, err := internal.ReadInventory(*filePath, logger)
-	if err != nil {
-		log.Printf("Error: Failed to read inventory: %v. Exiting the program due to inventory load failure.", err) // Log to the filePath
-		logger.Fatal("Exiting the program due to inventory load failure.", logger.Args("Reason", err))             // Log to the screen
-		os.Exit(1)
+	// Keep the raw output of every device, and the interface-like lines its parsers missed, for the archive
+	// (a replay reads its output from disk already)
+	rawDir := cfg.RawOutputDir
+	var rawFiles []string // Files written to rawDir by this run, the only ones archived and deleted
+	if cfg.ReplayDir != "" {
+		rawDir = ""
 	}
-
-	// Setup concurrency
-	logger.Trace("Initialising concurrency...") // Log to the screen
-	log.Printf("Initialising concurrency...")   // Log to the filePath
-	dataChan := make(chan internal.InterfaceData)
-	var wg sync.WaitGroup
-
-	// Counters for success and failure
-	var successCounter int
-	var failureCounter int
-	var mu sync.Mutex
-
-	// Set the number of workers
-	numWorkers := 10
-	workQueue := make(chan internal.Device, len(inventory.Devices))
-
-	logger.Trace("Launching worker goroutines for device processing...") // Log to the screen
-	log.Printf("Launching worker goroutines for device processing...")   // Log to the filePath
-
-	// Start worker goroutines
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for device := range workQueue {
-				internal.ProcessDevice(device, dataChan, *username, *password, selectedCommand, &successCounter, &failureCounter, &mu)
+	if rawDir != "" {
+		unparsed := 0
+		for _, result := range results {
+			written, count, err := internal.WriteRawOutput(rawDir, result)
+			rawFiles = append(rawFiles, written...)
+			if err != nil {
+				logger.Warn("Failed to save the raw output", logger.Args("Device", result.Device.Host), logger.Args("Reason", err))
+				log.Printf("Failed to save the raw output of %s: %v", result.Device.Host, err)
 			}
-		}()
+			unparsed += count
+		}
+		if unparsed > 0 {
+			logger.Warn("Some interface lines were not parsed: see the unparsed reports in the archive.", logger.Args("Lines", unparsed))
+		}
 	}
-	// Distribute work among workers
-	logger.Trace("Distributing tasks among workers...", logger.Args("Workers", numWorkers), logger.Args("Work Queue", len(inventory.Devices))) // Log to the screen
 
-	for _, device := range inventory.Devices {
-		workQueue <- device
+	// Count the outcomes and check if at least some devices were processed
+	outcomes := make(map[string]int)
+	for _, status := range statuses {
+		outcomes[status.Status]++
 	}
-	close(workQueue)
-
-	logger.Trace("Aggregating processed data...") // Log to the screen
-	log.Printf("Aggregating processed data...")   // Log to the filePath
-
-	// Use another goroutine to read from the channel and collect data
-	allData := make([]internal.InterfaceData, 0)
-	go func() {
-		for data := range dataChan {
-			allData = append(allData, data)
-			log.Printf("Received data: %+v", data)
-		}
-	}()
-
-	wg.Wait()       // Wait for all workers to finish processing
-	close(dataChan) // Safely close the data channel
-
-	// Check if at least some devices were processed
-	if failureCounter > 0 {
-		logger.Warn("Some devices encountered connection issues. Please check the application log for detailed error messages.", logger.Args("Total failed connections", failureCounter))
-		log.Printf("Some devices encountered connection issues. Total number of failed connections: %d", failureCounter)
+	if failed := outcomes[internal.StatusFailed] + outcomes[internal.StatusPartial]; failed > 0 {
+		logger.Warn("Some devices encountered connection or command issues. Please check the application log for detailed error messages.", logger.Args("Devices with issues", failed))
+		log.Printf("Some devices encountered connection or command issues. Total number of devices with issues: %d", failed)
+	}
+	if err := internal.WriteResultsFile(cfg.ResultsFile, statuses); err != nil {
+		logger.Warn("Failed to write the results file", logger.Args("Reason", err))
+		log.Printf("Failed to write the results file: %v", err)
 	}
 	// Check if data collection was successful
-	if len(allData) <= 0 {
+	if collected <= 0 {
 		log.Printf("Data collection failed: No interface data collected.")
 		logger.Error("Data collection failed: No interface data collected.")
 	} else {
-		log.Printf("All processing goroutines completed: Data channels closed successfully, and data collected for %d interfaces.", len(allData))
-		logger.Info("Data collection successful.", logger.Args("Total interfaces collected", len(allData)))
+		log.Printf("All processing goroutines completed: Results aggregated, and data collected for %d interfaces.", collected)
+		logger.Info("Data collection successful.", logger.Args("Total interfaces collected", collected))
 	}
 
 	// Perform Excel operations based on the command line option.
 	logger.Trace("Initiating Excel and data comparison operations, and preparing final reports....") // Log to the screen
 	log.Printf("Initiating Excel and data comparison operations, and preparing final reports...")    // Log to file
-	internal.ExcelOperations(allData, *baseFile, logger)
+	internal.ExcelOperations(allData, cfg, logger)
 
 	// 8. Zip the files
-	zipPath, err := internal.ZipAndDeleteFiles("./", logger)
+	zipPath, err := internal.ZipAndDeleteFiles("./", rawDir, rawFiles, cfg.RunID, logger)
 	if err != nil {
 		logger.Fatal("Failed to zip and delete files: ", logger.Args("error", err))
 		os.Exit(1)
@@ -193,19 +165,177 @@ func main() {
 	filesInfo := map[string]any{
 		"Application Log":     "Contains all runtime logs and errors - 'port-audit-application.log'",
 		"Excel Data File":     "Compiled interface data - 'PortAudit.xlsx'",
-		"Differences Archive": fmt.Sprintf("Zipped reports detailing differences - '%s'", zipPath),
+		"Differences Archive": fmt.Sprintf("Zipped reports detailing differences, raw device output and unparsed lines - '%s'", zipPath),
+		"Device Results":      fmt.Sprintf("Per-device status and failure class (JSON) - '%s'", cfg.ResultsFile),
 	}
 
 	// Log the comprehensive review message using a formatted string from the map.
 	logger.Info("Review the following generated files:", logger.ArgsFromMap(filesInfo))
 
 	// Reporting
-	totalNodes := len(inventory.Devices)
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Host < statuses[j].Host })
+	tableData := pterm.TableData{{"Device", "Platform", "Transport", "Status", "Failure", "Attempts", "Interfaces", "Error"}}
+	for _, status := range statuses {
+		tableData = append(tableData, []string{
+			status.Host, status.Platform, status.Transport, status.Status, string(status.Class),
+			strconv.Itoa(status.Attempts), strconv.Itoa(status.Interfaces), truncate(status.Error, 60),
+		})
+	}
+	fmt.Println()
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+	totalNodes := len(results)
 	elapsedTime := time.Since(startTime)
 	fmt.Println("\n----------------------------------------------------------------")
 	pterm.FgLightYellow.Printf("Total %d devices\n", totalNodes)
-	pterm.FgLightYellow.Printf("Successful connections: %d\n", successCounter)
-	pterm.FgLightYellow.Printf("Failed connections: %d\n", failureCounter)
+	pterm.FgLightYellow.Printf("Successful: %d\n", outcomes[internal.StatusOK])
+	pterm.FgLightYellow.Printf("Partial: %d\n", outcomes[internal.StatusPartial])
+	pterm.FgLightYellow.Printf("Failed: %d\n", outcomes[internal.StatusFailed])
+	if outcomes[internal.StatusNotCollected] > 0 {
+		pterm.FgLightRed.Printf("Not collected (run interrupted): %d\n", outcomes[internal.StatusNotCollected])
+	}
+	for _, host := range cleartextDevices {
+		pterm.FgLightRed.Printf("WARNING: %s was audited over cleartext telnet\n", host)
+	}
 	pterm.FgLightYellow.Printf("Execution Time: %s\n", elapsedTime)
 	fmt.Println("----------------------------------------------------------------")
 }
+
+/*
+Collect the interface data of every inventory device over SSH or telnet with a pool of workers.
+
+Returns:
+  - []internal.DeviceResult: The result of every device, in inventory order.
+  - []string: The devices audited over cleartext telnet, warned about again in the summary.
+*/
+func collectDevices(cfg *internal.Config, logger *pterm.Logger) (results []internal.DeviceResult, cleartextDevices []string) {
+	if cfg.PasswordSource == "flag" {
+		logger.Warn("The password was given with -p and is visible in shell history and the process list. Prefer the prompt, PORT_AUDIT_PASSWORD or -password-file.")
+	}
+	log.Printf("Password source: %q, command-line username needed: %t, command-line credentials needed: %t", cfg.PasswordSource, cfg.GlobalUsername, cfg.GlobalCredentials) // Never log the password itself
+
+	logger.Trace("Successfully passed the parameters for setup.") // log to the screen
+	log.Printf("Successfully passed the parameters for setup")    // Log to the filePath
+
+	// The command to run and the parser to use are selected per device from its platform (see internal/PlatformRegistry.go)
+	switch {
+	case cfg.Command != "":
+		logger.Info("Running the same command on every device.", logger.Args("Command", pterm.Green(cfg.Command)))
+	case cfg.Profile != internal.AutoProfile:
+		logger.Info("Commands are selected per device platform.", logger.Args("Profile", cfg.Profile))
+	default:
+		logger.Info("Commands are selected per device platform.", logger.Args("Supported platforms", strings.Join(internal.SupportedPlatforms(), ", ")))
+	}
+	log.Printf("Command selection: profile=%q command=%q interactive=%t", cfg.Profile, cfg.Command, cfg.Interactive)
+
+	// Build the host key verification used by every SSH connection
+	var err error
+	cfg.HostKeyCallback, err = internal.NewHostKeyCallback(cfg.HostKeyMode, cfg.KnownHostsFile)
+	if err != nil {
+		logger.Fatal("Exiting the program due to host key setup failure", logger.Args("Reason", err)) // Log to the screen
+		log.Printf("Exiting the program due to host key setup failure: %v", err)                      // Log to the filePath
+		os.Exit(1)
+	}
+	if cfg.HostKeyMode == internal.HostKeyInsecure {
+		logger.Warn("Host key verification is disabled: devices are not authenticated before credentials are sent.")
+	}
+
+	// Jump host connections are opened on first use and shared by all workers
+	cfg.Bastions = internal.NewBastionPool()
+	defer cfg.Bastions.Close()
+	if len(cfg.JumpHosts) > 0 {
+		log.Printf("Devices without their own jump hosts are reached through %d jump host(s)", len(cfg.JumpHosts))
+	}
+
+	// Read the inventory file
+	inventory, err := internal.ReadInventory(cfg.InventoryFile, logger)
+	if err != nil {
+		log.Printf("Error: Failed to read inventory: %v. Exiting the program due to inventory load failure.", err) // Log to the filePath
+		logger.Fatal("Exiting the program due to inventory load failure.", logger.Args("Reason", err))             // Log to the screen
+		os.Exit(1)
+	}
+
+	// Telnet sends credentials and output in cleartext: warn now and again in the summary
+	for _, device := range inventory.Devices {
+		if internal.DeviceTransport(device) == internal.TransportTelnet {
+			cleartextDevices = append(cleartextDevices, device.Host)
+		}
+	}
+	if len(cleartextDevices) > 0 {
+		logger.Warn("Some devices are audited over cleartext telnet: credentials and output can be read on the network.", logger.Args("Devices", strings.Join(cleartextDevices, ", ")))
+		log.Printf("WARNING: Devices audited over cleartext telnet: %v", cleartextDevices)
+	}
+
+	// Connection rate limit and per-group session caps shared by all workers
+	cfg.Limiter = internal.NewConnectionLimiter(cfg.ConnectRate, cfg.ConnectBurst, inventory.Groups, cfg.GroupSessions)
+	devices := inventory.Devices
+	queue := make([]int, len(devices)) // Order in which the devices are processed, as indexes into devices
+	for i := range queue {
+		queue[i] = i
+	}
+	if cfg.Limiter.Capped() {
+		queue = internal.InterleaveByGroup(devices) // Keep workers busy with other groups while a group is at its cap
+	}
+	log.Printf("Concurrency: %d workers, %.2f connections per second (burst %d)", cfg.Workers, cfg.ConnectRate, cfg.ConnectBurst)
+
+	// Ctrl-C or SIGTERM cancels the run: open sessions are closed and the data collected so far is still reported
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, cfg.RunTimeout, fmt.Errorf("run deadline of %s reached", cfg.RunTimeout))
+		defer cancel()
+	}
+	go func() {
+		<-ctx.Done()
+		stop() // Restore the default handling so a second Ctrl-C terminates at once
+	}()
+
+	// Setup concurrency
+	logger.Trace("Launching worker goroutines for device processing...", logger.Args("Workers", cfg.Workers), logger.Args("Work Queue", len(devices))) // Log to the screen
+	log.Printf("Launching %d worker goroutines for device processing...", cfg.Workers)                                                                 // Log to the filePath
+
+	// Each device's result is handed over by its worker and aggregated in inventory order (see internal/CollectResults.go)
+	results = internal.CollectResults(ctx, devices, queue, cfg.Workers, func(ctx context.Context, device internal.Device) internal.DeviceResult {
+		return internal.ProcessDevice(ctx, device, cfg)
+	})
+
+	if ctx.Err() != nil {
+		logger.Warn("The run was interrupted: reporting the data collected so far.", logger.Args("Reason", context.Cause(ctx)))
+		log.Printf("The run was interrupted (%v): reporting the data collected so far", context.Cause(ctx))
+	}
+	return results, cleartextDevices
+}
+
+// replayDevices parses the raw output saved by an earlier run (-replay) instead of connecting to the devices.
+// The inventory is optional and only provides the platform of each host.
+func replayDevices(cfg *internal.Config, logger *pterm.Logger) []internal.DeviceResult {
+	inventory := &internal.Inventory{}
+	if cfg.InventoryFile != "" {
+		var err error
+		inventory, err = internal.ReadInventory(cfg.InventoryFile, logger)
+		if err != nil {
+			log.Printf("Error: Failed to read inventory: %v. Exiting the program due to inventory load failure.", err) // Log to the filePath
+			logger.Fatal("Exiting the program due to inventory load failure.", logger.Args("Reason", err))             // Log to the screen
+			os.Exit(1)
+		}
+	}
+
+	logger.Info("Replaying saved device output, no device is contacted.", logger.Args("Directory", cfg.ReplayDir))
+	log.Printf("Replaying saved device output from %s", cfg.ReplayDir)
+	results, err := internal.ReplayRawOutput(cfg.ReplayDir, inventory)
+	if err != nil {
+		log.Printf("Error: Failed to replay saved output: %v", err)                            // Log to the filePath
+		logger.Fatal("Exiting the program due to replay failure.", logger.Args("Reason", err)) // Log to the screen
+		os.Exit(1)
+	}
+	return results
+}
+
+// truncate shortens a string to at most n characters for display in the summary table.
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	return text[:n-3] + "..."
+}
@@ -6,7 +6,7 @@ require (
 	github.com/pterm/pterm v0.12.79
 	github.com/tealeg/xlsx v1.0.5
 	golang.org/x/crypto v0.23.0
-	gopkg.in/yaml.v2 v2.2.4
+	golang.org/x/term v0.20.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -21,6 +21,5 @@ require (
 	github.com/rivo/uniseg v0.4.4 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
 	golang.org/x/sys v0.20.0 // indirect
-	golang.org/x/term v0.20.0 // indirect
 	golang.org/x/text v0.15.0 // indirect
 )
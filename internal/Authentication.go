@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// AuthOptions describes the credentials available for authenticating to a device.
+type AuthOptions struct {
+	Username string
+	Password string
+	KeyFile  string // Private key file, may be encrypted with a passphrase
+	UseAgent bool   // Use the SSH agent listening on SSH_AUTH_SOCK
+}
+
+var (
+	signerMu    sync.Mutex
+	signerCache = make(map[string]ssh.Signer) // Parsed private keys by file path, so each passphrase is asked once
+
+	agentOnce   sync.Once
+	agentClient agent.ExtendedAgent
+	agentErr    error
+
+	// keyboardPasswordPattern matches the keyboard-interactive questions asking for the account password, e.g.
+	// "Password:", "admin's password:" or "Password for admin@switch:", but not "One-time password:".
+	keyboardPasswordPattern = regexp.MustCompile(`(?i)^\s*(\S+'s\s+)?password(\s+for\s+\S+)?\s*:?\s*$`)
+)
+
+/*
+Build the SSH authentication methods for a device from its credentials.
+
+Parameters:
+  - opts AuthOptions: The credentials resolved for the device.
+  - interactive bool: Whether the user can be prompted for a key passphrase.
+
+Returns:
+  - []ssh.AuthMethod: The methods in the order they are tried: agent, private key, password, keyboard-interactive.
+  - error: Returned if a configured key or agent cannot be used, or no method is available.
+*/
+
+func BuildAuthMethods(opts AuthOptions, interactive bool) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if opts.UseAgent {
+		client, err := sshAgent()
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeysCallback(client.Signers))
+	}
+
+	if opts.KeyFile != "" {
+		signer, err := loadSigner(opts.KeyFile, interactive)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if opts.Password != "" {
+		methods = append(methods, ssh.Password(opts.Password))
+		methods = append(methods, ssh.KeyboardInteractive(passwordChallenge(opts.Password))) // Fallback for devices that only offer keyboard-interactive
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no authentication method configured for user %s (password, key or agent)", opts.Username)
+	}
+	return methods, nil
+}
+
+// sshAgent connects once to the SSH agent given by SSH_AUTH_SOCK and shares the client between all connections.
+func sshAgent() (agent.ExtendedAgent, error) {
+	agentOnce.Do(func() {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			agentErr = fmt.Errorf("SSH agent authentication requested but SSH_AUTH_SOCK is not set")
+			return
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			agentErr = fmt.Errorf("failed to connect to SSH agent: %v", err)
+			return
+		}
+		agentClient = agent.NewClient(conn)
+		log.Printf("Connected to SSH agent at %s", socket)
+	})
+	return agentClient, agentErr
+}
+
+// loadSigner parses a private key file, asking for its passphrase when the key is encrypted.
+func loadSigner(path string, interactive bool) (ssh.Signer, error) {
+	signerMu.Lock()
+	defer signerMu.Unlock()
+
+	if signer, ok := signerCache[path]; ok {
+		return signer, nil
+	}
+
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %v", path, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase, perr := keyPassphrase(path, interactive)
+		if perr != nil {
+			return nil, perr
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %v", path, err)
+	}
+
+	signerCache[path] = signer
+	log.Printf("Loaded private key %s (%s)", path, signer.PublicKey().Type())
+	return signer, nil
+}
+
+// keyPassphrase returns the passphrase of an encrypted key from PORT_AUDIT_KEY_PASSPHRASE or a no-echo prompt.
+func keyPassphrase(path string, interactive bool) ([]byte, error) {
+	if passphrase := os.Getenv("PORT_AUDIT_KEY_PASSPHRASE"); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if !interactive {
+		return nil, fmt.Errorf("private key %s is encrypted: set PORT_AUDIT_KEY_PASSPHRASE or run from a terminal", path)
+	}
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", path)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase for %s: %v", path, err)
+	}
+	return passphrase, nil
+}
+
+// passwordChallenge answers keyboard-interactive prompts asking for a password with the configured password. Any other
+// question, such as a one-time password or a second factor, fails the method rather than burning a login attempt
+// with the password.
+func passwordChallenge(password string) ssh.KeyboardInteractiveChallenge {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			if !keyboardPasswordPattern.MatchString(question) {
+				return nil, fmt.Errorf("keyboard-interactive question %q is not a password prompt", strings.TrimSpace(question))
+			}
+			answers[i] = password
+		}
+		return answers, nil
+	}
+}
+
+// expandHome replaces a leading "~/" in a path with the user's home directory.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,27 @@
+package internal
+
+import "testing"
+
+func TestPasswordChallengeOnlyAnswersPasswordPrompts(t *testing.T) {
+	challenge := passwordChallenge("secret")
+	for _, question := range []string{"Password:", "password: ", "admin's password:", "Password for admin@switch:", "Password"} {
+		answers, err := challenge("admin", "", []string{question}, []bool{false})
+		if err != nil || len(answers) != 1 || answers[0] != "secret" {
+			t.Errorf("%q: answers = %q, %v, want the password", question, answers, err)
+		}
+	}
+	for _, questions := range [][]string{
+		{"One-time password:"},
+		{"Verification code:"},
+		{"Enter PASSCODE:"},
+		{"Password:", "Duo two-factor login, passcode or option (1-2):"},
+	} {
+		if answers, err := challenge("admin", "", questions, make([]bool, len(questions))); err == nil {
+			t.Errorf("%q: answered %q, want the method to fail", questions, answers)
+		}
+	}
+	// Challenges without question, e.g. a banner, need no answer
+	if answers, err := challenge("admin", "Authorised use only", nil, nil); err != nil || len(answers) != 0 {
+		t.Errorf("banner: answers = %q, %v, want none", answers, err)
+	}
+}
@@ -6,20 +6,86 @@ Cisco Port Audit
 Ensure you have the Port Allocation Spreadsheet in the home directory (*unless you are creating a new spreadsheet)
 - File name: PortAudit.xlsx
 - Sheet name: Baseline (*used for comparison)
+- Each run adds a sheet named after its start time, "Audit DDMMYYYY-HHMMSS.mmm-xxxx"
+  (xxxx is random), and a report_<run ID>.zip archive, so several audits can be run on the same day, even together.
 
 Follow these steps to use the tool:
 
-Example: port-audit -u admin -p admin123 -f inventory.yml
+Example: port-audit -u admin -f inventory.yml
 
-1. Enter SSH username and password (only SSH is currently supported).
+1. Enter the username; the password is prompted for without echo. For unattended runs set PORT_AUDIT_PASSWORD
+   or use --password-file, or use a private key (--key) / SSH agent (--agent) for SSH devices.
 2. Provide the file path to the inventory file.
-3. Select the command to run on the devices from the interactive menu (show interface description/show interface status).
-4. The application will read the inventory file and execute the selected command on each device.
+3. Set the platform of each device in the inventory file (ios, iosxe, nxos, iosxr).
+4. The application will read the inventory file and execute the command registered for each device's platform:
+   - ios, iosxe, nxos: show interface status
+   - iosxr: show int description
 5. The results will be logged, and the Excel file will be updated/created.
 6. Difference report files will be generated per device.
+7. The run ends with a per-device status table; the same results are written as JSON to
+   port-audit-results.json (--results). Failures are classified as dns, tcp-refused, timeout, auth,
+   host-key, command-rejected or no-rows. dns, tcp-refused and timeout failures are retried
+   (--retries, --retry-backoff doubling on each retry).
 
 Note:
 - The inventory file can be generated using --gen flag (Create YAML Inventory File).
+- Use --profile full on IOS/IOS-XE to run both show interface status and show interface description and get one
+  row per port with every column filled (VLAN, duplex, speed and type plus the full description).
+- Use --profile or --command to change the command selection, e.g. --profile description or
+  --command "show interface status,show interface description". Several commands run over one connection
+  and their results are merged into one row per interface. The run never prompts when stdin is not a terminal (cron, CI).
+- Options can also be read from a YAML file with --config; flags override the file.
+- A device can use its own private key or the SSH agent with key_file / agent in the inventory.
+  Encrypted keys read the passphrase from PORT_AUDIT_KEY_PASSPHRASE or prompt for it.
+- Devices with transport: telnet are audited over telnet (password login only). Telnet is cleartext: every
+  such device is flagged with a warning at the start of the run and in the summary.
+- IOS and IOS-XE devices are audited in an interactive shell (PTY) with paging disabled; other platforms use
+  SSH exec requests. Override with --exec-mode shell|exec and tune --command-timeout for slow devices.
+- Devices and groups can carry their own credentials in the inventory (username plus password_env,
+  password_file or password_keyring); the command-line credentials are used for anything not set.
+  No command-line username, or password, key or agent, is needed when every device and jump host has its own.
+- Devices behind a bastion are reached with --jump user@bastion:22[,next-hop] or jump_hosts in the
+  configuration file, an inventory group or a device. One connection per bastion is shared by all workers.
+- Device host keys are verified with --host-key: tofu (default) records new keys in port-audit_known_hosts
+  and rejects changed keys, strict only accepts keys already in ~/.ssh/known_hosts or port-audit_known_hosts,
+  insecure disables verification. Host key failures are listed per device in the run summary.
+- Tune the load on devices and AAA servers with --workers (devices processed at once, default 10),
+  --connect-rate / --connect-burst (new logins per second) and max_sessions on an inventory group
+  (or --group-sessions for every group) to cap the sessions open at once to one site.
+- Ctrl-C (or SIGTERM) and --run-timeout stop the run cleanly: open sessions are closed, the data already
+  collected is written to the Excel file and compared, and the remaining devices are marked "not collected".
+  Press Ctrl-C a second time to exit at once.
+- The raw output of every command is saved as raw_output/<host>__<command>.txt (--raw-dir) and archived with
+  the difference reports. Interface lines the parsers did not match are listed per device in
+  unparsed_report_<host>.txt in the same directory.
+- --replay <dir> re-runs the parsing and the comparison on saved output (an extracted raw_output directory or
+  a backup dump named <host>__<command>.txt) without connecting to any device; no credentials are needed and
+  --f is optional, only used for the platform of each host. The replay directory is never deleted.
+- Output is parsed with TextFSM templates. --templates <dir> loads a directory laid out like ntc-templates
+  (an index file plus .textfsm templates) whose templates are used before the built-in ones, so a platform or
+  a command can be added or fixed without a new build (e.g. --templates ./ntc-templates/templates with
+  platform: cisco_nxos or a platform missing from the built-in list together with --command).
+
+- --reference selects the sheet the audit is compared against: baseline (default), previous (the most recent
+  earlier Audit sheet), sheet:<name> or date:<YYYY-MM-DD> (the Audit sheet of that day). --reference-file reads
+  it from a separate workbook instead of PortAudit.xlsx. Each report names the reference in its header.
+- --compare-fields selects the fields compared against the baseline (default description,status) and
+  --compare-rules the rule of each field: exact, case-insensitive, whitespace (repeated spaces ignored), regex
+  (values equal on the part captured by a pattern, set in the configuration file) and +ignore-blank (fields blank
+  in the baseline are not compared). Speed and duplex ignore the "a-" of negotiated values (a-1000 = 1000), and
+  the status treats connected = up (up), notconnect = down (down) and disabled = admin down (down) as equal.
+- --waivers <file> suppresses known findings: each waiver matches a node glob, an interface regex, a description
+  regex and/or a field (a compared field, new or missing) and carries an owner, a reason, a ticket and an expiry
+  date (YYYY-MM-DD). Waived findings are listed in a separate "Waived findings" section of the report; once a
+  waiver expires its findings are reported again. Ports described "Faulty Port" in the baseline are still skipped.
+
+Example of configuration file (YAML format):
+--------------------------------------
+username: admin
+inventory: inventory.yml
+profile: auto
+no_color: true
+--------------------------------------
 
 Example of inventory file (YAML format):
 --------------------------------------
@@ -32,20 +98,82 @@ devices:
     port: 22
     platform: ios
     transport: ssh
+    key_file: ~/.ssh/lab_ed25519
+    agent: false
 --------------------------------------
 
 Flags:
+  -agent
+        Authenticate with the SSH agent (SSH_AUTH_SOCK)
   -base
         Create initial Excel file with a baseline sheet
+  -command string
+        Commands to run on every device, separated by commas, overrides -profile (e.g. "show interface status")
+  -config string
+        Path to a YAML configuration file
+  -command-timeout duration
+        Maximum time a single command may take in shell mode (default 1m0s)
+  -compare-fields string
+        Fields compared against the reference: description, status, vlan, speed, duplex, type, slot, port (default "description,status")
+  -compare-rules string
+        Comparison rule per field, e.g. "description=whitespace,vlan=exact+ignore-blank" (exact, case-insensitive, whitespace, regex, ignore-blank)
+  -connect-burst int
+        Connections allowed back to back before -connect-rate applies (default 1)
+  -connect-rate float
+        New device connections per second across the run, 0 for unlimited
+  -dial-timeout duration
+        Maximum time to establish a connection to a device or jump host (default 5s)
+  -exec-mode string
+        How commands are run: auto (by platform), shell (interactive PTY) or exec (default "auto")
   -f string
         File path
   -gen
         Generate a YAML inventory file from a list of devices
+  -group-sessions int
+        Concurrent sessions per inventory group without its own max_sessions, 0 for unlimited
+  -host-key string
+        Host key verification: strict (known_hosts only), tofu (record new keys) or insecure (no verification) (default "tofu")
+  -jump string
+        Jump hosts for every device without its own, ProxyJump style (e.g. admin@bastion:22,bastion2)
+  -key string
+        Private key file for SSH public key authentication
+  -known-hosts string
+        known_hosts file used by port-audit, new keys are recorded here in tofu mode (default "port-audit_known_hosts")
+  -no-color
+        Disable coloured screen output
+  -non-interactive
+        Never prompt for input (implied when stdin is not a terminal)
   -p string
-        Password for device access
+        Password for device access (visible in shell history and ps, prefer the prompt, PORT_AUDIT_PASSWORD or -password-file)
+  -password-file string
+        File holding the password for device access
+  -profile string
+        Collection profile: auto (command by platform), status, description or full (status and description joined per port) (default "auto")
+  -raw-dir string
+        Directory receiving the raw output of every command, archived with the reports (empty to disable) (default "raw_output")
+  -reference string
+        Sheet the audit is compared against: baseline, previous (latest earlier Audit sheet), sheet:<name> or date:<YYYY-MM-DD> (default "baseline")
+  -reference-file string
+        Workbook holding the reference sheet, if not the audit workbook PortAudit.xlsx
+  -replay string
+        Parse the saved <host>__<command>.txt output of this directory instead of connecting to devices
+  -results string
+        File receiving the per-device results as JSON (default "port-audit-results.json")
+  -retries int
+        Extra attempts for devices failing with DNS, refused connection or timeout errors (default 2)
+  -retry-backoff duration
+        Wait before the first retry, doubled for every further retry (default 2s)
+  -run-timeout duration
+        Deadline of the whole run (e.g. 30m), 0 for none; devices not audited in time are reported as not collected
+  -templates string
+        Directory of TextFSM templates with an ntc-templates style index, used before the built-in parsers
   -u string
         Username for device access
   -usage
         Display the usage guide
+  -waivers string
+        YAML file of waivers suppressing known findings (owner, reason, ticket and expiry per waiver)
+  -workers int
+        Number of devices processed concurrently (default 10)
 
 `
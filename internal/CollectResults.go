@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// indexedResult is the result of a device together with its position in the inventory.
+type indexedResult struct {
+	index int
+	DeviceResult
+}
+
+/*
+Process the devices with a pool of workers and aggregate their results.
+
+Parameters:
+  - ctx context.Context: Cancelled to stop the run; process is expected to return early with a not collected result.
+  - devices []Device: The inventory devices.
+  - queue []int: The order in which the devices are processed, as indexes into devices (see InterleaveByGroup).
+  - workers int: The number of devices processed concurrently.
+  - process func(context.Context, Device) DeviceResult: Processes one device, normally ProcessDevice.
+
+Returns:
+  - []DeviceResult: The result of every device, in inventory order whatever order the devices completed in.
+
+Each worker hands the result of a device over on a channel, nothing else is shared. The results are aggregated on the
+calling goroutine, which returns once every worker has returned and every result has been received.
+*/
+
+func CollectResults(ctx context.Context, devices []Device, queue []int, workers int, process func(context.Context, Device) DeviceResult) []DeviceResult {
+	resultChan := make(chan indexedResult)
+	workQueue := make(chan int, len(queue))
+	var wg sync.WaitGroup
+
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range workQueue {
+				resultChan <- indexedResult{index: index, DeviceResult: process(ctx, devices[index])}
+			}
+		}()
+	}
+
+	// Distribute work among workers
+	for _, index := range queue {
+		workQueue <- index
+	}
+	close(workQueue)
+
+	// Close the result channel once every worker has returned, which ends the aggregation below
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	results := make([]DeviceResult, len(devices))
+	for result := range resultChan {
+		log.Printf("Received %d interfaces from %s (%s)", len(result.Rows), result.Device.Host, result.Status.Status)
+		results[result.index] = result.DeviceResult
+	}
+	return results
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestCollectResultsKeepsEveryRowInInventoryOrder(t *testing.T) {
+	devices := make([]Device, 50)
+	for i := range devices {
+		devices[i] = Device{Host: fmt.Sprintf("sw%02d", i), Platform: "ios"}
+	}
+	// Reversed, so devices complete in a different order than the inventory
+	queue := make([]int, len(devices))
+	for i := range queue {
+		queue[i] = len(devices) - 1 - i
+	}
+
+	// Stub of ProcessDevice: device i returns i rows after a random delay
+	process := func(ctx context.Context, device Device) DeviceResult {
+		var index int
+		fmt.Sscanf(device.Host, "sw%d", &index)
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+		rows := make([]InterfaceData, index)
+		for i := range rows {
+			rows[i] = InterfaceData{Node: device.Host, Interface: fmt.Sprintf("GigabitEthernet1/0/%d", i+1)}
+		}
+		return DeviceResult{Device: device, Rows: rows, Status: DeviceStatus{Host: device.Host, Status: StatusOK, Interfaces: len(rows)}}
+	}
+
+	for _, workers := range []int{1, 4, 50, 100} {
+		results := CollectResults(context.Background(), devices, queue, workers, process)
+		if len(results) != len(devices) {
+			t.Fatalf("workers=%d: %d results, want %d", workers, len(results), len(devices))
+		}
+		rows := 0
+		for i, result := range results {
+			if result.Device.Host != devices[i].Host {
+				t.Errorf("workers=%d: result %d is %q, want %q", workers, i, result.Device.Host, devices[i].Host)
+			}
+			if len(result.Rows) != i {
+				t.Errorf("workers=%d: %s has %d rows, want %d", workers, result.Device.Host, len(result.Rows), i)
+			}
+			rows += len(result.Rows)
+		}
+		if want := len(devices) * (len(devices) - 1) / 2; rows != want {
+			t.Errorf("workers=%d: %d rows collected, want %d", workers, rows, want)
+		}
+	}
+}
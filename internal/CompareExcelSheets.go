@@ -6,6 +6,8 @@ import (
 	"github.com/tealeg/xlsx"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -22,30 +24,47 @@ func FilterData(refData, newData []InterfaceData, logger *pterm.Logger) []Interf
 			filteredData = append(filteredData, d)
 		}
 	}
-	log.Printf("Filtered data", filteredData) // send to the log file
+	log.Printf("Filtered data: %v", filteredData) // send to the log file
 	//logger.Trace("FilterData", logger.Args(filteredData))
 	return filteredData
 
 }
 
-// CompareExcelSheets compares two Excel sheets for differences.
-func CompareExcelSheets(filename string, logger *pterm.Logger) error {
+// CompareExcelSheets compares the audit sheet of the run (cfg.RunID) with the reference sheet for differences, on the
+// fields and with the rules of cfg.Compare.
+func CompareExcelSheets(filename string, cfg *Config, logger *pterm.Logger) error {
 	file, err := xlsx.OpenFile(filename)
 	if err != nil {
 		logger.Warn("Failed to open Excel file", logger.Args("Reason", err))
 		return fmt.Errorf("Failed to open Excel file: %v", err)
 	}
 
-	dateTime := time.Now().Format("02012006") // Generates a timestamp for naming the new audit sheet.
-	refSheet := file.Sheet["Baseline"]
-	newSheetName := fmt.Sprintf("Audit %s", dateTime)
+	newSheetName := AuditSheetName(cfg.RunID) // The sheet written by this run
 	newSheet := file.Sheet[newSheetName]
+	if newSheet == nil {
+		logger.Warn("Missing Excel sheets for comparison (new sheet not found)")
+		return fmt.Errorf("Missing Excel sheets for comparison (new sheet not found)")
+	}
 
-	if refSheet == nil || newSheet == nil {
-		logger.Warn("Missing Excel sheets for comparison (reference or new sheet not found)")
-		return fmt.Errorf("Missing Excel sheets for comparison (reference or new sheet not found)")
-
+	// The reference is a sheet of the same workbook unless a separate workbook is given
+	refFile, refFileName := file, filename
+	if cfg.ReferenceFile != "" {
+		refFileName = cfg.ReferenceFile
+		if refFile, err = xlsx.OpenFile(refFileName); err != nil {
+			logger.Warn("Failed to open reference Excel file", logger.Args("Reason", err))
+			return fmt.Errorf("Failed to open reference Excel file: %v", err)
+		}
+		newSheetName = ""
+	}
+	runTime, _ := runIDTime(cfg.RunID)
+	refSheet, err := SelectReferenceSheet(refFile, cfg.Reference, newSheetName, runTime)
+	if err != nil {
+		logger.Warn("Missing Excel sheets for comparison (reference sheet not found)", logger.Args("Reason", err))
+		return fmt.Errorf("Missing Excel sheets for comparison: %v", err)
 	}
+	reference := fmt.Sprintf("sheet '%s' of %s", refSheet.Name, refFileName)
+	logger.Info("Comparing the new audit against its reference.", logger.Args("Reference", reference))
+	log.Printf("Comparing sheet '%s' against the reference %s", newSheet.Name, reference)
 
 	refData, err := ReadExcelData(refSheet) // Read data from the reference sheet.
 	if err != nil {
@@ -59,116 +78,308 @@ func CompareExcelSheets(filename string, logger *pterm.Logger) error {
 		return fmt.Errorf("Failed to read new sheet data: %v", err)
 	}
 
+	// Both sheets use the same interface names from here on, whatever the command or IOS version that produced them
+	refData = NormalizeInterfaceNames(refData)
+	newData = NormalizeInterfaceNames(newData)
+
 	filteredRefData := FilterData(refData, newData, logger) // Filter the reference data
 
-	diffCount := compareData(filteredRefData, newData) // Compare data from the two sheets
-	log.Printf("Audit completed: %d differences found", diffCount)
-	logger.Trace("Completed data comparison.", logger.Args("Differences found", diffCount))
+	// Fields without a column in the reference sheet have no reference value to compare against
+	policy, missingFields := cfg.Compare.WithColumns(SheetHeaders(refSheet))
+	if len(missingFields) > 0 {
+		logger.Warn("The reference sheet has no column for some compared fields, they are not compared.", logger.Args("Fields", strings.Join(missingFields, ", ")))
+		log.Printf("The reference %s has no column for %s: not compared", reference, strings.Join(missingFields, ", "))
+	}
+	log.Printf("Comparing %s", policy.Describe())
+	totals := compareData(filteredRefData, newData, policy, cfg.Waivers, reference) // Compare data from the two sheets
+	log.Printf("Audit completed: %d differences found, %d baseline ports missing from the devices, %d findings waived, %d findings with an expired waiver",
+		totals.diffs, totals.missing, totals.waived, totals.expired)
+	logger.Trace("Completed data comparison.", logger.Args("Differences found", totals.diffs, "Ports missing from devices", totals.missing, "Findings waived", totals.waived))
+	if totals.expired > 0 {
+		logger.Warn("Some findings matched expired waivers and are reported again: renew or remove the waivers.", logger.Args("Findings", totals.expired))
+	}
+	if totals.ambiguous > 0 {
+		logger.Warn("Some ports share their SLOT, PORT and TYPE with another port and were not compared: add an Interface column to the reference.", logger.Args("Ports", totals.ambiguous))
+		log.Printf("%d ports were not compared: their SLOT, PORT and TYPE are shared by another port", totals.ambiguous)
+	}
 	return nil
 }
 
+// compareTotals counts the findings of a comparison.
+type compareTotals struct {
+	diffs     int // Ports differing from the reference, not counting waived differences
+	missing   int // Reference ports missing from devices that were collected, not counting waived ones
+	waived    int // Findings suppressed by an active waiver
+	expired   int // Findings reported again because their waiver expired
+	ambiguous int // New ports not compared because their slot/port key is shared by another port
+}
+
 // CompareData evaluates differences between two slices of InterfaceData (reference data and new data).
-func compareData(refData, newData []InterfaceData) int {
-	nodeFiles := make(map[string]*os.File)
+// Reference ports missing from devices that were collected are reported too; the ports of devices that were not
+// collected and the reference ports described "Faulty Port" are not reported missing. Findings matching an active waiver are listed in the separate waived section
+// of the report instead, and findings matching an expired waiver are reported with a note. The reference is named in
+// the header of every report.
+func compareData(refData, newData []InterfaceData, policy ComparePolicy, waivers []*Waiver, reference string) compareTotals {
+	var totals compareTotals
+	now := time.Now()
+	nodeFiles := make(map[string]*strings.Builder)          // Report body of each node, written once the summary is known
+	waivedFiles := make(map[string]*strings.Builder)        // Waived findings of each node
 	statusSummary := make(map[string]map[string]int)        // A nested map to track status summaries per node.
 	currentTime := time.Now().Format("02-01-2006 15:04:05") // DD-MM-YYYY HH:MM:SS
 
+	notCollected := make(map[string]bool) // Nodes without interface data in newData, e.g. after a connection failure
+	newKeys := make(map[string]bool)      // Ports found in newData
+
+	// Rows are matched on the interface name, or on the slot and port when a sheet has rows without one
+	key, ambiguous := matchKeys(refData, newData)
+
 	// Prepare files and status summary for nodes found in newData
 	for _, d := range newData {
 		if _, exists := nodeFiles[d.Node]; !exists {
-			reportFile := fmt.Sprintf("audit_report_%s_%s.txt", d.Node, currentTime)
-			file, err := os.Create(reportFile)
-			if err != nil {
-				log.Fatalf("Failed to create report file for node %s: %v", d.Node, err)
-			}
-			nodeFiles[d.Node] = file
+			nodeFiles[d.Node] = &strings.Builder{}
+			waivedFiles[d.Node] = &strings.Builder{}
 			statusSummary[d.Node] = make(map[string]int) // Initialise status count map for this node
-
-			// Write the initial part of the report
-			_, _ = file.WriteString(fmt.Sprintf("Audit Report for %s generated on: %s\n", d.Node, currentTime))
 		}
+		if isNotCollectedRow(d) {
+			notCollected[d.Node] = true
+			_, _ = nodeFiles[d.Node].WriteString("Device not collected: no interface data was gathered, no comparison performed\n")
+			_, _ = nodeFiles[d.Node].WriteString("-----------------------------------\n")
+			continue
+		}
+		newKeys[key(d)] = true
 		statusSummary[d.Node][d.Status]++ // Increment count for this status
 	}
 
 	// Map reference data for comparison
 	refMap := make(map[string]InterfaceData)
 	for _, d := range refData {
-		key := fmt.Sprintf("%s-%s-%s", d.Node, d.Slot, d.Port)
-		refMap[key] = d
+		if isNotCollectedRow(d) {
+			continue
+		}
+		refMap[key(d)] = d
+	}
+
+	// checkWaiver returns whether a finding is waived, and the note telling which waiver applies or has expired
+	checkWaiver := func(node, interfaceName, refDescription, newDescription, field string) (bool, string) {
+		active, expired := findWaiver(waivers, now, node, interfaceName, refDescription, newDescription, field)
+		switch {
+		case active != nil:
+			totals.waived++
+			return true, fmt.Sprintf("Waived (%s)\n", active)
+		case expired != nil:
+			totals.expired++
+			return false, fmt.Sprintf("Waiver expired (%s)\n", expired)
+		}
+		return false, ""
 	}
 
 	// Compare new data against reference data and write differences
-	diffCount := 0
 	for _, d := range newData {
-		key := fmt.Sprintf("%s-%s-%s", d.Node, d.Slot, d.Port)
-		ref, exists := refMap[key]
+		if isNotCollectedRow(d) {
+			continue
+		}
 		file, fileExists := nodeFiles[d.Node]
 
+		// Ports sharing their slot, port and type with another port cannot be told apart without Interface column
+		if ambiguous[key(d)] {
+			totals.ambiguous++
+			_, _ = file.WriteString(fmt.Sprintf("Ambiguous port for Node: %s, Interface: %s, Slot: %s, Port: %s\n", d.Node, d.Interface, d.Slot, d.Port))
+			_, _ = file.WriteString("Not compared: another port has the same SLOT, PORT and TYPE and the reference has no Interface column\n")
+			_, _ = file.WriteString("-----------------------------------\n")
+			continue
+		}
+		ref, exists := refMap[key(d)]
+
 		// Skip comparison if the reference data description is "Faulty Port"
 		if exists && ref.Description == "Faulty Port" {
 			continue
 		}
 
 		if exists && fileExists {
-			diffs := compareFields(ref, d)
-			if len(diffs) > 0 {
-				diffCount++
-				diff := fmt.Sprintf("Difference found for Node: %s, Slot: %s, Port: %s\n", d.Node, d.Slot, d.Port)
-				for _, diffDetail := range diffs {
-					diff += diffDetail + "\n"
+			var diff, waivedDiff string
+			for _, fieldDiff := range compareFields(ref, d, policy) {
+				waived, note := checkWaiver(d.Node, d.Interface, ref.Description, d.Description, fieldDiff.field)
+				if waived {
+					waivedDiff += fieldDiff.text + "\n" + note
+				} else {
+					diff += fieldDiff.text + "\n" + note
 				}
-				diff += "-----------------------------------\n"
-				_, _ = file.WriteString(diff)
+			}
+			heading := fmt.Sprintf("for Node: %s, Interface: %s, Slot: %s, Port: %s\n", d.Node, d.Interface, d.Slot, d.Port)
+			if diff != "" {
+				totals.diffs++
+				_, _ = file.WriteString("Difference found " + heading + diff + "-----------------------------------\n")
+			}
+			if waivedDiff != "" {
+				_, _ = waivedFiles[d.Node].WriteString("Difference waived " + heading + waivedDiff + "-----------------------------------\n")
 			}
 		} else if !exists && fileExists {
-			newEntry := fmt.Sprintf("New entry detected for Node: %s, Slot: %s, Port: %s\n", d.Node, d.Slot, d.Port)
-			newEntry += "-----------------------------------\n"
+			waived, note := checkWaiver(d.Node, d.Interface, "", d.Description, FindingNew)
+			newEntry := fmt.Sprintf("New entry detected for Node: %s, Interface: %s, Slot: %s, Port: %s\n", d.Node, d.Interface, d.Slot, d.Port)
+			newEntry += note + "-----------------------------------\n"
+			if waived {
+				file = waivedFiles[d.Node]
+			}
 			_, _ = file.WriteString(newEntry)
 		}
 	}
 
-	// Write the status summary at the top of each report file and close files
-	for node, file := range nodeFiles {
-		summaryInfo := "\nStatus Summary:\n"
-		for status, count := range statusSummary[node] {
-			summaryInfo += fmt.Sprintf("%s: %d\n", status, count)
+	// Reverse pass: reference ports the device no longer reports (removed linecard, failed stack member, renumbering).
+	// Devices that were not collected are skipped, their ports are unknown rather than missing.
+	skipped := make(map[string]int)
+	for _, d := range refData {
+		if isNotCollectedRow(d) || newKeys[key(d)] {
+			continue
+		}
+		// Ports described "Faulty Port" in the reference are skipped, as in the forward pass
+		if d.Description == "Faulty Port" {
+			continue
+		}
+		if notCollected[d.Node] {
+			skipped[d.Node]++
+			continue
+		}
+		file, fileExists := nodeFiles[d.Node]
+		if !fileExists {
+			continue
+		}
+		waived, note := checkWaiver(d.Node, d.Interface, d.Description, "", FindingMissing)
+		missing := fmt.Sprintf("Missing from device for Node: %s, Interface: %s, Slot: %s, Port: %s\n", d.Node, d.Interface, d.Slot, d.Port)
+		missing += fmt.Sprintf("Reference: Description(%s) Status(%s)\n", d.Description, d.Status)
+		missing += note + "-----------------------------------\n"
+		if waived {
+			file = waivedFiles[d.Node]
+		} else {
+			totals.missing++
+		}
+		_, _ = file.WriteString(missing)
+	}
+	for node, count := range skipped {
+		_, _ = nodeFiles[node].WriteString(fmt.Sprintf("%d baseline ports were not checked (device not collected, not reported missing)\n", count))
+		log.Printf("Device %s was not collected: %d baseline ports were not checked", node, count)
+	}
+
+	// Write each report file: the header, the status summary, then the entries
+	for node, body := range nodeFiles {
+		reportFile := fmt.Sprintf("audit_report_%s_%s.txt", node, currentTime)
+		summaryInfo := fmt.Sprintf("Audit Report for %s generated on: %s\n", node, currentTime)
+		summaryInfo += fmt.Sprintf("Compared against: %s\n", reference)
+		summaryInfo += "\nStatus Summary:\n"
+		statuses := make([]string, 0, len(statusSummary[node]))
+		for status := range statusSummary[node] {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			summaryInfo += fmt.Sprintf("%s: %d\n", status, statusSummary[node][status])
 		}
 		summaryInfo += "===================================\n"
-		file.Seek(0, 0) // Go back to the beginning of the file
-		_, _ = file.WriteString(summaryInfo)
-		file.Close()
-		log.Printf("Differences report for %s saved to 'audit_report_%s.txt'", node, node)
+		report := summaryInfo + body.String()
+		if waived := waivedFiles[node].String(); waived != "" {
+			report += "\nWaived findings:\n===================================\n" + waived
+		}
+		if err := os.WriteFile(reportFile, []byte(report), 0644); err != nil {
+			log.Fatalf("Failed to create report file for node %s: %v", node, err)
+		}
+		log.Printf("Differences report for %s saved to '%s'", node, reportFile)
 	}
 
-	return diffCount
+	return totals
 }
 
-// Check if two InterfaceData objects are identical and return the fields that are different.
-func compareFields(a, b InterfaceData) []string {
-	var diffs []string
-	//if a.Type != b.Type {
-	//	diffs = append(diffs, fmt.Sprintf("Type: Reference(%s) New(%s)", a.Type, b.Type))
-	//}
-	if a.Description != b.Description {
-		diffs = append(diffs, fmt.Sprintf("Description: Reference(%s) New(%s)", a.Description, b.Description))
-	}
-	if a.Status != b.Status {
-		diffs = append(diffs, fmt.Sprintf("Status: Reference(%s) New(%s)", a.Status, b.Status))
-	}
-	//if a.Speed != b.Speed {
-	//	diffs = append(diffs, fmt.Sprintf("Speed: Reference(%s) New(%s)", a.Speed, b.Speed))
-	//}
-	//if a.Duplex != b.Duplex {
-	//	diffs = append(diffs, fmt.Sprintf("Duplex: Reference(%s) New(%s)", a.Duplex, b.Duplex))
-	//}
-	//if a.VLAN != b.VLAN {
-	//	diffs = append(diffs, fmt.Sprintf("VLAN: Reference(%s) New(%s)", a.VLAN, b.VLAN))
-	//}
-	//if a.Port != b.Port {
-	//	diffs = append(diffs, fmt.Sprintf("Port: Reference(%s) New(%s)", a.Port, b.Port))
-	//}
-	//if a.Slot != b.Slot {
-	//	diffs = append(diffs, fmt.Sprintf("Slot: Reference(%s) New(%s)", a.Slot, b.Slot))
-	//}
+// interfaceKey returns the key matching a row of the reference sheet to a row of the new sheet: the node and the
+// normalised interface name, so stack members and modules never collide (see InterfaceName).
+func interfaceKey(d InterfaceData) string {
+	return d.Node + "|" + ParseInterfaceName(d.Interface).Key()
+}
+
+// slotPortKey returns the key matching the rows of sheets written without an Interface column: the node, the SLOT
+// and PORT joined into one path, taken from the interface name when the row has one, so "1/0" "24" and the "1" "0/24"
+// of earlier versions match, and the TYPE when withType is set, so Gi1/0/1 and Te1/0/1 stay apart.
+func slotPortKey(d InterfaceData, withType bool) string {
+	slot, port := d.Slot, d.Port
+	if d.Interface != "" {
+		name := ParseInterfaceName(d.Interface)
+		if column := name.PortColumn(); column != "" {
+			slot, port = name.SlotColumn(), column
+		}
+	}
+	path := port
+	if slot != "" {
+		path = slot + "/" + port
+	}
+	key := d.Node + "|" + path
+	if withType {
+		key += "|" + strings.ToLower(strings.TrimSpace(d.Type))
+	}
+	return key
+}
+
+/*
+Select how the rows of the reference and the new data are matched.
+
+Parameters:
+  - refData []InterfaceData: The rows of the reference sheet.
+  - newData []InterfaceData: The rows of the new sheet.
+
+Returns:
+  - func(InterfaceData) string: The key of a row, computed the same way for both sheets.
+  - map[string]bool: The keys shared by several rows of the same sheet, whose rows cannot be matched.
+
+Rows are matched on the interface name when every row has one. Otherwise, e.g. against a baseline written without
+Interface column, both sheets are matched on the slot and port, and on the TYPE when every row has one. Rows that
+still share a key within a sheet, e.g. Fa0/1 and Gi0/1 without TYPE, are ambiguous.
+*/
+
+func matchKeys(refData, newData []InterfaceData) (func(InterfaceData) string, map[string]bool) {
+	withInterface, withType := true, true
+	for _, rows := range [][]InterfaceData{refData, newData} {
+		for _, d := range rows {
+			if isNotCollectedRow(d) {
+				continue
+			}
+			withInterface = withInterface && d.Interface != ""
+			withType = withType && strings.TrimSpace(d.Type) != ""
+		}
+	}
+	if withInterface {
+		return interfaceKey, nil
+	}
+
+	key := func(d InterfaceData) string { return slotPortKey(d, withType) }
+	ambiguous := make(map[string]bool)
+	for _, rows := range [][]InterfaceData{refData, newData} {
+		seen := make(map[string]bool)
+		for _, d := range rows {
+			if isNotCollectedRow(d) {
+				continue
+			}
+			if k := key(d); seen[k] {
+				ambiguous[k] = true
+			} else {
+				seen[k] = true
+			}
+		}
+	}
+	log.Printf("Matching rows on SLOT and PORT (TYPE: %t): the reference or the new sheet has rows without Interface, %d keys are ambiguous", withType, len(ambiguous))
+	return key, ambiguous
+}
+
+// fieldDiff is a field that differs from the reference, with its line of the report.
+type fieldDiff struct {
+	field string
+	text  string
+}
+
+// Compare the fields selected by the policy and return the fields that are different.
+func compareFields(a, b InterfaceData, policy ComparePolicy) []fieldDiff {
+	var diffs []fieldDiff
+	for _, field := range policy.Fields {
+		name := compareFieldNames[field]
+		reference, value := name.value(a), name.value(b)
+		if !policy.Rules[field].Equal(reference, value) {
+			diffs = append(diffs, fieldDiff{field: field, text: fmt.Sprintf("%s: Reference(%s) New(%s)", name.label, reference, value)})
+		}
+	}
 	return diffs
 }
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pterm/pterm"
+	"github.com/tealeg/xlsx"
+)
+
+// inTempDir runs the test in a temporary working directory, where the reports are written.
+func inTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+	return dir
+}
+
+// addTestSheet adds a sheet holding the header row and the data rows to a workbook.
+func addTestSheet(t *testing.T, file *xlsx.File, name string, headers []string, rows ...[]string) {
+	t.Helper()
+	sheet, err := file.AddSheet(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range append([][]string{headers}, rows...) {
+		row := sheet.AddRow()
+		for _, value := range values {
+			row.AddCell().Value = value
+		}
+	}
+}
+
+func TestCompareExcelSheetsSkipsFieldsWithoutReferenceColumn(t *testing.T) {
+	dir := inTempDir(t)
+	file := xlsx.NewFile()
+	// A baseline written before VLAN, duplex, speed and type were collected
+	addTestSheet(t, file, "Baseline", []string{"Switch Name", "Interface", "SLOT", "PORT", "Port Status", "Port Description"},
+		[]string{"sw1", "GigabitEthernet1/0/1", "1/0", "1", "connected", "uplink"},
+		[]string{"sw1", "GigabitEthernet1/0/2", "1/0", "2", "notconnect", "spare"},
+	)
+	cfg := DefaultConfig()
+	cfg.RunID = "18102026-090000"
+	addTestSheet(t, file, AuditSheetName(cfg.RunID), []string{"Switch Name", "Interface", "SLOT", "PORT", "TYPE", "Port Status", "VLAN", "Duplex", "SPEED", "Port Description"},
+		[]string{"sw1", "Gi1/0/1", "1/0", "1", "10/100/1000BaseTX", "connected", "10", "a-full", "a-1000", "uplink"},
+		[]string{"sw1", "Gi1/0/2", "1/0", "2", "10/100/1000BaseTX", "notconnect", "20", "auto", "auto", "printer"},
+	)
+	filename := filepath.Join(dir, "PortAudit.xlsx")
+	if err := file.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Compare.Fields = []string{"description", "vlan", "speed", "type"}
+	if err := cfg.Compare.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareExcelSheets(filename, cfg, pterm.DefaultLogger.WithWriter(io.Discard)); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, _ := filepath.Glob(filepath.Join(dir, "audit_report_sw1_*.txt"))
+	if len(reports) != 1 {
+		t.Fatalf("found %d reports for sw1, want 1", len(reports))
+	}
+	report, err := os.ReadFile(reports[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the description, which the baseline has, is compared
+	if got := strings.Count(string(report), "Difference found"); got != 1 {
+		t.Errorf("report has %d differences, want 1:\n%s", got, report)
+	}
+	if !strings.Contains(string(report), "Description: Reference(spare) New(printer)") {
+		t.Errorf("report misses the description difference:\n%s", report)
+	}
+	if strings.Contains(string(report), "VLAN:") || strings.Contains(string(report), "Speed:") || strings.Contains(string(report), "Type:") {
+		t.Errorf("report compares fields missing from the baseline:\n%s", report)
+	}
+}
+
+func TestCompareDataSkipsFaultyPortsInBothPasses(t *testing.T) {
+	dir := inTempDir(t)
+	refData := []InterfaceData{
+		{Node: "sw1", Interface: "GigabitEthernet1/0/1", Slot: "1/0", Port: "1", Description: "uplink", Status: "connected"},
+		{Node: "sw1", Interface: "GigabitEthernet1/0/2", Slot: "1/0", Port: "2", Description: "Faulty Port", Status: "notconnect"},
+		{Node: "sw1", Interface: "GigabitEthernet1/0/3", Slot: "1/0", Port: "3", Description: "Faulty Port", Status: "notconnect"},
+		{Node: "sw1", Interface: "GigabitEthernet1/0/4", Slot: "1/0", Port: "4", Description: "printer", Status: "connected"},
+	}
+	// Gi1/0/2 changed and Gi1/0/3 is gone, both are faulty in the baseline; Gi1/0/4 is really missing
+	newData := []InterfaceData{
+		{Node: "sw1", Interface: "GigabitEthernet1/0/1", Slot: "1/0", Port: "1", Description: "uplink", Status: "connected"},
+		{Node: "sw1", Interface: "GigabitEthernet1/0/2", Slot: "1/0", Port: "2", Description: "replaced", Status: "connected"},
+	}
+	policy := DefaultConfig().Compare
+	if err := policy.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	totals := compareData(refData, newData, policy, nil, "sheet 'Baseline'")
+	if totals.diffs != 0 || totals.missing != 1 {
+		t.Errorf("totals = %+v, want no difference and 1 missing port", totals)
+	}
+	reports, _ := filepath.Glob(filepath.Join(dir, "audit_report_sw1_*.txt"))
+	if len(reports) != 1 {
+		t.Fatalf("found %d reports for sw1, want 1", len(reports))
+	}
+	report, err := os.ReadFile(reports[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(report), "Port: 2\n") || strings.Contains(string(report), "Port: 3\n") {
+		t.Errorf("report lists a faulty port:\n%s", report)
+	}
+	if !strings.Contains(string(report), "Missing from device for Node: sw1, Interface: GigabitEthernet1/0/4") {
+		t.Errorf("report misses the missing port:\n%s", report)
+	}
+}
+
+func TestCompareExcelSheetsMatchesReferenceWithoutInterfaceColumn(t *testing.T) {
+	dir := inTempDir(t)
+	file := xlsx.NewFile()
+	// Gi1/0/1 and Te1/0/1 share their SLOT and PORT, and Gi1/0/2 is split as earlier versions did
+	addTestSheet(t, file, "Baseline", []string{"Switch Name", "SLOT", "PORT", "TYPE", "Port Status", "Port Description"},
+		[]string{"sw1", "1/0", "1", "10/100/1000BaseTX", "connected", "uplink"},
+		[]string{"sw1", "1", "0/2", "10/100/1000BaseTX", "notconnect", "spare"},
+		[]string{"sw1", "1/0", "1", "SFP-10GBase-SR", "connected", "core"},
+	)
+	cfg := DefaultConfig()
+	cfg.RunID = "18102026-090000"
+	addTestSheet(t, file, AuditSheetName(cfg.RunID), []string{"Switch Name", "Interface", "SLOT", "PORT", "TYPE", "Port Status", "Port Description"},
+		[]string{"sw1", "GigabitEthernet1/0/1", "1/0", "1", "10/100/1000BaseTX", "connected", "uplink"},
+		[]string{"sw1", "GigabitEthernet1/0/2", "1/0", "2", "10/100/1000BaseTX", "notconnect", "spare"},
+		[]string{"sw1", "TenGigabitEthernet1/0/1", "1/0", "1", "SFP-10GBase-SR", "connected", "core"},
+	)
+	filename := filepath.Join(dir, "PortAudit.xlsx")
+	if err := file.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Compare.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareExcelSheets(filename, cfg, pterm.DefaultLogger.WithWriter(io.Discard)); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, _ := filepath.Glob(filepath.Join(dir, "audit_report_sw1_*.txt"))
+	if len(reports) != 1 {
+		t.Fatalf("found %d reports for sw1, want 1", len(reports))
+	}
+	report, err := os.ReadFile(reports[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, finding := range []string{"Difference found", "New entry", "Missing from device", "Ambiguous port"} {
+		if strings.Contains(string(report), finding) {
+			t.Errorf("report has a %q finding, want none:\n%s", finding, report)
+		}
+	}
+}
+
+func TestCompareDataRefusesAmbiguousSlotAndPort(t *testing.T) {
+	inTempDir(t)
+	// Without Interface and TYPE, Fa0/1 and Gi0/1 of the baseline have the same SLOT and PORT
+	refData := []InterfaceData{
+		{Node: "sw1", Slot: "0", Port: "1", Description: "access", Status: "connected"},
+		{Node: "sw1", Slot: "0", Port: "1", Description: "uplink", Status: "connected"},
+		{Node: "sw1", Slot: "0", Port: "2", Description: "printer", Status: "connected"},
+	}
+	newData := []InterfaceData{
+		{Node: "sw1", Interface: "FastEthernet0/1", Slot: "0", Port: "1", Description: "access", Status: "connected"},
+		{Node: "sw1", Interface: "GigabitEthernet0/1", Slot: "0", Port: "1", Description: "uplink", Status: "connected"},
+		{Node: "sw1", Interface: "FastEthernet0/2", Slot: "0", Port: "2", Description: "printer", Status: "connected"},
+	}
+	policy := DefaultConfig().Compare
+	if err := policy.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	totals := compareData(refData, newData, policy, nil, "sheet 'Baseline'")
+	if totals.ambiguous != 2 || totals.diffs != 0 || totals.missing != 0 {
+		t.Errorf("totals = %+v, want the 2 ports of 0/1 ambiguous and no other finding", totals)
+	}
+
+	// With the TYPE on both sides the ports are told apart
+	refData[0].Type, refData[1].Type, refData[2].Type = "10/100BaseTX", "1000BaseSX SFP", "10/100BaseTX"
+	newData[0].Type, newData[1].Type, newData[2].Type = "10/100BaseTX", "1000BaseSX SFP", "10/100BaseTX"
+	totals = compareData(refData, newData, policy, nil, "sheet 'Baseline'")
+	if totals != (compareTotals{}) {
+		t.Errorf("totals = %+v, want no finding", totals)
+	}
+}
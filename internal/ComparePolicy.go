@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Comparison rules (FieldRule.Match).
+const (
+	MatchExact           = "exact"            // Values must be identical
+	MatchCaseInsensitive = "case-insensitive" // Values are compared ignoring case
+	MatchWhitespace      = "whitespace"       // Values are compared with leading, trailing and repeated spaces removed
+	MatchRegex           = "regex"            // Values are compared on the part captured by FieldRule.Pattern
+	MatchIgnoreBlank     = "ignore-blank"     // Flag form of FieldRule.IgnoreBlank, e.g. "exact+ignore-blank"
+)
+
+// FieldRule describes how one field of the reference and of the new data are compared.
+type FieldRule struct {
+	Match       string     `yaml:"match"`        // exact (default), case-insensitive, whitespace or regex
+	Pattern     string     `yaml:"pattern"`      // regex rule: values are equal when their first group (or whole match) is
+	IgnoreBlank bool       `yaml:"ignore_blank"` // Do not compare the field when the reference value is blank
+	Groups      [][]string `yaml:"groups"`       // Values of the same group are equal, e.g. [connected, "up (up)"]
+
+	pattern *regexp.Regexp
+	groups  map[string]int // Normalised value to its group
+}
+
+// ComparePolicy selects the fields of InterfaceData compared against the reference and the rule of each field.
+type ComparePolicy struct {
+	Fields []string              `yaml:"fields"` // Compared fields, in report order
+	Rules  map[string]*FieldRule `yaml:"rules"`  // Rule of each field, keyed by field name; fields without a rule are exact
+}
+
+// compareFieldNames maps the field names of a policy to the report label, the sheet column and the value of the field.
+var compareFieldNames = map[string]struct {
+	label  string
+	header string
+	value  func(InterfaceData) string
+}{
+	"description": {"Description", "Port Description", func(d InterfaceData) string { return d.Description }},
+	"status":      {"Status", "Port Status", func(d InterfaceData) string { return d.Status }},
+	"vlan":        {"VLAN", "VLAN", func(d InterfaceData) string { return d.VLAN }},
+	"speed":       {"Speed", "SPEED", func(d InterfaceData) string { return d.Speed }},
+	"duplex":      {"Duplex", "Duplex", func(d InterfaceData) string { return d.Duplex }},
+	"type":        {"Type", "TYPE", func(d InterfaceData) string { return d.Type }},
+	"slot":        {"Slot", "SLOT", func(d InterfaceData) string { return d.Slot }},
+	"port":        {"Port", "PORT", func(d InterfaceData) string { return d.Port }},
+}
+
+// autoNegotiated captures a speed or duplex without the "a-" prefix 'show interface status' adds to negotiated values.
+const autoNegotiated = `^(?:a-)?(.*)$`
+
+// DefaultComparePolicy compares the description and the status, as port-audit always did. The rules of the other fields
+// apply once they are selected, e.g. with -compare-fields.
+func DefaultComparePolicy() ComparePolicy {
+	return ComparePolicy{
+		Fields: []string{"description", "status"},
+		Rules: map[string]*FieldRule{
+			"description": {Match: MatchExact},
+			// The status and description commands word the same state differently
+			"status": {Match: MatchCaseInsensitive, Groups: [][]string{
+				{"connected", "up", "up (up)"},
+				{"notconnect", "down", "down (down)", "up (down)"},
+				{"disabled", "admin down", "admin down (down)", "administratively down", "administratively down (down)"},
+			}},
+			"speed":  {Match: MatchRegex, Pattern: autoNegotiated},
+			"duplex": {Match: MatchRegex, Pattern: autoNegotiated},
+		},
+	}
+}
+
+/*
+Check the policy and compile its patterns and groups; run once before the comparison.
+
+Returns:
+  - error: Returned if a field or rule is unknown, or a pattern is not a valid regular expression.
+*/
+func (p *ComparePolicy) Prepare() error {
+	rules := make(map[string]*FieldRule, len(p.Rules))
+	for field, rule := range p.Rules {
+		rules[strings.ToLower(strings.TrimSpace(field))] = rule
+	}
+	p.Rules = rules
+	for i, field := range p.Fields {
+		p.Fields[i] = strings.ToLower(strings.TrimSpace(field))
+		if _, ok := compareFieldNames[p.Fields[i]]; !ok {
+			return fmt.Errorf("unknown comparison field %q (supported: %s)", field, joinSorted(compareFieldNames))
+		}
+	}
+	for field, rule := range p.Rules {
+		if _, ok := compareFieldNames[field]; !ok {
+			return fmt.Errorf("comparison rule for unknown field %q (supported: %s)", field, joinSorted(compareFieldNames))
+		}
+		if rule == nil {
+			rule = &FieldRule{Match: MatchExact}
+			p.Rules[field] = rule
+		}
+		switch rule.Match {
+		case "", MatchExact, MatchCaseInsensitive, MatchWhitespace:
+		case MatchRegex:
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil || rule.Pattern == "" {
+				return fmt.Errorf("comparison rule for %s: invalid pattern %q: %v", field, rule.Pattern, err)
+			}
+			rule.pattern = pattern
+		default:
+			return fmt.Errorf("comparison rule for %s: unknown match %q (supported: %s, %s, %s, %s)", field, rule.Match, MatchExact, MatchCaseInsensitive, MatchWhitespace, MatchRegex)
+		}
+		rule.groups = make(map[string]int)
+		for i, group := range rule.Groups {
+			for _, value := range group {
+				rule.groups[rule.normalize(value)] = i
+			}
+		}
+	}
+	return nil
+}
+
+/*
+Set the rules of fields from a "field=rule,field=rule" list, as given with -compare-rules.
+
+A rule is a match (exact, case-insensitive, whitespace or regex) optionally followed by "+ignore-blank", or
+"ignore-blank" alone for an exact comparison skipping blank reference values. The pattern and the groups of the
+field's existing rule are kept, so "speed=regex" reuses the default speed pattern.
+*/
+func (p *ComparePolicy) SetRules(list string) error {
+	if p.Rules == nil {
+		p.Rules = make(map[string]*FieldRule)
+	}
+	for _, entry := range SplitCommands(list) {
+		field, spec, found := strings.Cut(entry, "=")
+		if !found {
+			return fmt.Errorf("invalid comparison rule %q, expected field=rule", entry)
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		rule := &FieldRule{}
+		if existing := p.Rules[field]; existing != nil {
+			rule.Pattern, rule.Groups = existing.Pattern, existing.Groups
+		}
+		for _, part := range strings.Split(spec, "+") {
+			switch part = strings.ToLower(strings.TrimSpace(part)); part {
+			case MatchIgnoreBlank:
+				rule.IgnoreBlank = true
+			default:
+				rule.Match = part
+			}
+		}
+		p.Rules[field] = rule
+	}
+	return nil
+}
+
+// WithColumns returns the policy without the fields whose column is missing from a sheet, e.g. a baseline written
+// before VLAN, speed, duplex and type were collected, and the fields left out.
+func (p ComparePolicy) WithColumns(headers map[string]int) (ComparePolicy, []string) {
+	var fields, missing []string
+	for _, field := range p.Fields {
+		if _, ok := headers[compareFieldNames[field].header]; ok {
+			fields = append(fields, field)
+		} else {
+			missing = append(missing, field)
+		}
+	}
+	p.Fields = fields
+	return p, missing
+}
+
+// normalize returns the form of a value the rule compares.
+func (r *FieldRule) normalize(value string) string {
+	switch r.Match {
+	case MatchCaseInsensitive:
+		return strings.ToLower(strings.TrimSpace(value))
+	case MatchWhitespace:
+		return strings.Join(strings.Fields(value), " ")
+	case MatchRegex:
+		if r.pattern == nil {
+			return value
+		}
+		matches := r.pattern.FindStringSubmatch(value)
+		switch {
+		case matches == nil:
+			return value
+		case len(matches) > 1:
+			return matches[1]
+		default:
+			return matches[0]
+		}
+	}
+	return value
+}
+
+// Equal reports whether a new value matches the reference value under the rule; a nil rule compares exactly.
+func (r *FieldRule) Equal(reference, value string) bool {
+	if r == nil {
+		return reference == value
+	}
+	if r.IgnoreBlank && strings.TrimSpace(reference) == "" {
+		return true
+	}
+	a, b := r.normalize(reference), r.normalize(value)
+	if a == b {
+		return true
+	}
+	groupA, okA := r.groups[a]
+	groupB, okB := r.groups[b]
+	return okA && okB && groupA == groupB
+}
+
+// Describe returns the policy as "field (rule)" entries, for the log and the reports.
+func (p ComparePolicy) Describe() string {
+	entries := make([]string, 0, len(p.Fields))
+	for _, field := range p.Fields {
+		rule := p.Rules[field]
+		match := MatchExact
+		if rule != nil && rule.Match != "" {
+			match = rule.Match
+		}
+		if rule != nil && rule.IgnoreBlank {
+			match += "+" + MatchIgnoreBlank
+		}
+		entries = append(entries, fmt.Sprintf("%s (%s)", field, match))
+	}
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config holds the run options collected from the command line and the optional configuration file.
+type Config struct {
+	Username       string        `yaml:"username"`
+	Password       string        `yaml:"-"`             // Never read from or written to the configuration file
+	PasswordFile   string        `yaml:"password_file"` // File holding the password on its first line
+	KeyFile        string        `yaml:"key_file"`      // Private key used for every device without its own key_file
+	UseAgent       bool          `yaml:"agent"`         // Authenticate with the SSH agent (SSH_AUTH_SOCK)
+	InventoryFile  string        `yaml:"inventory"`
+	BaseFile       bool          `yaml:"base"`
+	Profile        string        `yaml:"profile"` // Collection profile: auto (by platform), status, description or full
+	Command        string        `yaml:"command"` // Explicit commands run on every device (comma-separated), override Profile
+	NoColor        bool          `yaml:"no_color"`
+	NonInteractive bool          `yaml:"non_interactive"`
+	HostKeyMode    string        `yaml:"host_key"`        // Host key verification: strict, tofu or insecure
+	KnownHostsFile string        `yaml:"known_hosts"`     // port-audit known_hosts file, new keys are recorded here in tofu mode
+	ExecMode       string        `yaml:"exec_mode"`       // auto (by platform), shell or exec
+	CommandTimeout time.Duration `yaml:"command_timeout"` // Maximum time a single command may take in shell mode
+	JumpHosts      []JumpHost    `yaml:"jump_hosts"`      // Bastions traversed to reach devices without their own jump hosts
+	DialTimeout    time.Duration `yaml:"dial_timeout"`    // Maximum time to establish a connection
+	Retries        int           `yaml:"retries"`         // Extra attempts for DNS, refused and timeout failures
+	RetryBackoff   time.Duration `yaml:"retry_backoff"`   // Wait before the first retry, doubled for every further retry
+	ResultsFile    string        `yaml:"results_file"`    // Machine-readable per-device results (JSON)
+	Workers        int           `yaml:"workers"`         // Devices processed concurrently
+	ConnectRate    float64       `yaml:"connect_rate"`    // New device connections per second, 0 for unlimited
+	ConnectBurst   int           `yaml:"connect_burst"`   // Connections allowed back to back before ConnectRate applies
+	GroupSessions  int           `yaml:"group_sessions"`  // Concurrent sessions per inventory group without max_sessions, 0 for unlimited
+	RunTimeout     time.Duration `yaml:"run_timeout"`     // Deadline of the whole run, 0 for none; devices not audited in time are reported as not collected
+	RawOutputDir   string        `yaml:"raw_output_dir"`  // Directory receiving the raw output of every command, archived with the reports; empty to disable
+	ReplayDir      string        `yaml:"replay"`          // Parse the <host>__<command>.txt files of this directory instead of connecting to devices
+	TemplateDir    string        `yaml:"templates"`       // TextFSM templates with an ntc-templates style index, used before the built-in parsers
+	Compare        ComparePolicy `yaml:"compare"`         // Fields compared against the reference and the rule of each field
+	WaiversFile    string        `yaml:"waivers"`         // YAML file of the waivers suppressing known findings until their expiry
+	Reference      string        `yaml:"reference"`       // Sheet compared against: baseline, previous, sheet:<name> or date:<YYYY-MM-DD>
+	ReferenceFile  string        `yaml:"reference_file"`  // Workbook holding the reference sheet, the audit workbook when empty
+
+	ConfigFile  string `yaml:"-"`
+	GenerateInv bool   `yaml:"-"`
+	UsageGuide  bool   `yaml:"-"`
+	Interactive bool   `yaml:"-"` // Set when stdin is a terminal and prompting is allowed
+
+	PasswordSource    string `yaml:"-"` // Where the password came from (flag, file, env or prompt), logged instead of the password
+	GlobalUsername    bool   `yaml:"-"` // Set when a device or jump host falls back to the command-line username
+	GlobalCredentials bool   `yaml:"-"` // Set when a device or jump host falls back to the command-line password, key or agent
+
+	HostKeyCallback ssh.HostKeyCallback `yaml:"-"` // Built from HostKeyMode before devices are processed
+	Bastions        *BastionPool        `yaml:"-"` // Jump host connections shared by all workers
+	Limiter         *ConnectionLimiter  `yaml:"-"` // Connection rate limit and per-group session caps, built from the inventory
+	Waivers         []*Waiver           `yaml:"-"` // Loaded from WaiversFile
+	RunID           string              `yaml:"-"` // Start time of the run, naming its audit sheet (see AuditSheetName)
+}
+
+// DefaultConfig returns the configuration used when neither a flag nor the configuration file sets a value.
+func DefaultConfig() *Config {
+	return &Config{
+		Profile:        "auto",
+		HostKeyMode:    HostKeyTOFU,
+		KnownHostsFile: "port-audit_known_hosts",
+		ExecMode:       ExecModeAuto,
+		CommandTimeout: 60 * time.Second,
+		DialTimeout:    5 * time.Second,
+		Retries:        2,
+		RetryBackoff:   2 * time.Second,
+		ResultsFile:    "port-audit-results.json",
+		Workers:        10,
+		ConnectBurst:   1,
+		RawOutputDir:   "raw_output",
+		Compare:        DefaultComparePolicy(),
+		Reference:      ReferenceBaseline,
+	}
+}
+
+/*
+Load a YAML configuration file on top of the given configuration.
+
+Parameters:
+  - path string: The path to the YAML configuration file.
+  - cfg *Config: The configuration to update; fields missing from the file are left unchanged.
+
+Returns:
+  - error: Returned if the file cannot be read or parsed.
+*/
+
+func LoadConfigFile(path string, cfg *Config) error {
+	log.Printf("Reading configuration file %s...", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %v", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to unmarshal configuration file: %v", err)
+	}
+	return nil
+}
+
+// findConfigPath returns the value of the -config flag from the raw arguments so the file can be loaded before flags are parsed.
+func findConfigPath(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue // Not a flag
+		}
+		if strings.HasPrefix(name, "config=") {
+			return strings.TrimPrefix(name, "config=")
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
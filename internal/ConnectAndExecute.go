@@ -1,57 +1,97 @@
 package internal
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"strconv"
-	"sync"
+	"strings"
+	"time"
 )
 
 /*
-Establish an SSH connection to a device and execute a specified command,
-then send the processed output to a data channel.
+Establish an SSH or telnet connection (Device.Transport) to a device and execute the commands registered for its platform
+over that one connection, returning their raw output for parsing (see ParseOutputs).
 
 Parameters:
+  ctx context.Context - The run context; cancelling it closes the connection and abandons the remaining commands.
   device Device - The device structure containing the necessary details like Host and Port.
-  username string - SSH username for authentication.
-  password string - SSH password for authentication.
-  dataChan chan<- InterfaceData - A channel to send processed interface data to.
+  cfg *Config - The run configuration holding the credentials and the command selection.
 
 Returns:
-  error - Returns an error if any step in the process fails
+  []CommandOutput - The raw output of every command run, with its duration and error.
+  error - Returns an error if any step in the process fails; a *CommandError if a command was rejected.
+          The output of the commands completed before a cancellation is still returned.
 */
 
-func ConnectAndExecute(device Device, username, password string, dataChan chan<- InterfaceData, selectedCommand string, successCounter *int, failureCounter *int, mu *sync.Mutex) error {
+func ConnectAndExecute(ctx context.Context, device Device, cfg *Config) ([]CommandOutput, error) {
+	profile, err := ResolveProfile(device.Platform, cfg.Profile, cfg.Command)
+	if err != nil {
+		log.Printf("Error: Cannot audit host %s: %v", device.Host, err)
+		return nil, err
+	}
+
 	port, err := strconv.Atoi(device.Port)
 	if err != nil {
 		log.Printf("Error: Invalid port number for host %s: %v", device.Host, err)
-		return err
+		return nil, err
 	}
 
-	session, err := InitialiseConnection(device.Host, port, username, password)
+	// Device and group credentials take precedence over the command-line ones
+	opts, err := ResolveCredentials(device, cfg)
 	if err != nil {
-		log.Printf("Error: SSH connection failed for %s; error: %v", device.Host, err)
-		mu.Lock()
-		*failureCounter++
-		mu.Unlock()
-		return err
+		log.Printf("Error: Failed to resolve credentials for %s: %v", device.Host, err)
+		return nil, err
 	}
-	defer session.Close()
-	log.Printf("SSH connection established for %s", device.Host)
-	mu.Lock()
-	*successCounter++
-	mu.Unlock()
-
-	command := selectedCommand
-	log.Printf("Executing command on %s: %s", device.Host, command)
-
-	output, err := session.CombinedOutput(command)
+	// Connection rate limit of the run
+	if err := cfg.Limiter.Wait(ctx); err != nil {
+		return nil, interrupted(ctx, err)
+	}
+	executor, closeConnection, err := OpenExecutor(ctx, device, port, opts, profile, cfg)
 	if err != nil {
-		log.Printf("Error: Failed to execute command on %s: %v", device.Host, err)
-		return err
+		log.Printf("Error: Connection failed for %s over %s; error: %v", device.Host, DeviceTransport(device), err)
+		return nil, err
+	}
+	// Cancelling the run closes the connection, which makes the running command return at once
+	stop := context.AfterFunc(ctx, closeConnection)
+	defer func() {
+		if stop() {
+			closeConnection()
+		}
+	}()
+	log.Printf("%s connection established for %s", strings.ToUpper(DeviceTransport(device)), device.Host)
+
+	// Run every command of the profile over the same connection
+	var outputs []CommandOutput
+	var commandErr error
+	completed := 0
+	for _, command := range profile.Commands {
+		if ctx.Err() != nil {
+			break
+		}
+		log.Printf("Executing command on %s (%s): %s", device.Host, profile.Name, command)
+		start := time.Now()
+		output, err := executor.Run(command)
+		if err == nil {
+			err = checkCommandOutput(command, output)
+		} else {
+			err = &CommandError{Command: command, Reason: err.Error()}
+		}
+		outputs = append(outputs, CommandOutput{Command: command, Output: output, Duration: time.Since(start), Err: err})
+		if err != nil {
+			log.Printf("Error: Failed to execute command on %s: %v", device.Host, err)
+			if commandErr == nil {
+				commandErr = err
+			}
+			continue
+		}
+		completed++
+		log.Printf("Command executed successfully on %s in %s", device.Host, time.Since(start))
 	}
-	log.Printf("Command executed successfully on %s, processing output...", device.Host)
 
-	ProcessOutput(string(output), command, device, dataChan)
-	log.Printf("Output processed for %s", device.Host)
-	return nil
+	if ctx.Err() != nil {
+		log.Printf("Audit of %s interrupted after %d of %d commands", device.Host, completed, len(profile.Commands))
+		commandErr = interrupted(ctx, fmt.Errorf("%d of %d commands completed", completed, len(profile.Commands)))
+	}
+	return outputs, commandErr
 }
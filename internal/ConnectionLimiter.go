@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+ConnectionLimiter throttles the connections opened to devices: a token bucket limits the rate of new connections
+across the whole run, and a semaphore per inventory group caps the sessions open at once to the devices of that group
+(e.g. a site whose TACACS server throttles logins).
+
+A nil limiter allows everything, so callers do not need to check whether limits are configured.
+*/
+type ConnectionLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // New connections per second, 0 for unlimited
+	burst  float64 // Connections allowed back to back before the rate applies
+	tokens float64
+	last   time.Time
+
+	groups map[string]chan struct{} // Session slots per group, groups without a cap are absent
+}
+
+/*
+Create the limiter of a run.
+
+Parameters:
+  - rate float64: New connections per second across all devices; 0 disables the rate limit.
+  - burst int: Connections allowed back to back before the rate applies (at least 1).
+  - groups map[string]Group: The inventory groups; their max_sessions caps the concurrent sessions of their members.
+  - defaultSessions int: Cap for the groups without max_sessions; 0 leaves them uncapped.
+
+Returns:
+  - *ConnectionLimiter: The limiter shared by all workers.
+*/
+
+func NewConnectionLimiter(rate float64, burst int, groups map[string]Group, defaultSessions int) *ConnectionLimiter {
+	burst = max(burst, 1)
+	l := &ConnectionLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now(), groups: make(map[string]chan struct{})}
+	for name, group := range groups {
+		sessions := group.MaxSessions
+		if sessions == 0 {
+			sessions = defaultSessions
+		}
+		if sessions > 0 {
+			l.groups[name] = make(chan struct{}, sessions)
+			log.Printf("Group %s is limited to %d concurrent sessions", name, sessions)
+		}
+	}
+	return l
+}
+
+// Wait blocks until a new connection may be opened under the rate limit, or the run is cancelled.
+func (l *ConnectionLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.rate <= 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// Take the token now, even if it is not there yet, so waiting callers are served in order
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the token back, the connection is not opened and the next callers need not wait for it
+		l.mu.Lock()
+		l.tokens = math.Min(l.burst, l.tokens+1)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Acquire blocks until a session slot of the group is free and returns the function releasing it.
+// The error is returned when the run is cancelled while waiting; there is nothing to release then.
+func (l *ConnectionLimiter) Acquire(ctx context.Context, group string) (func(), error) {
+	if l == nil {
+		return func() {}, ctx.Err()
+	}
+	slots, ok := l.groups[group]
+	if !ok {
+		return func() {}, ctx.Err()
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return func() {}, ctx.Err()
+	}
+}
+
+// Capped reports whether at least one group has a session cap.
+func (l *ConnectionLimiter) Capped() bool {
+	return l != nil && len(l.groups) > 0
+}
+
+/*
+Order the devices so consecutive devices belong to different groups, round-robin over the groups.
+
+With per-group caps, a queue holding a whole site in a row would leave every worker waiting on that site's cap;
+interleaving keeps the workers busy with the other groups meanwhile. The inventory order is kept within each group.
+The order is returned as indexes into devices, so results can still be reported in inventory order.
+*/
+func InterleaveByGroup(devices []Device) []int {
+	byGroup := make(map[string][]int)
+	for i, device := range devices {
+		byGroup[device.Group] = append(byGroup[device.Group], i)
+	}
+	names := make([]string, 0, len(byGroup))
+	for name := range byGroup {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ordered := make([]int, 0, len(devices))
+	for len(ordered) < len(devices) {
+		for _, name := range names {
+			if queue := byGroup[name]; len(queue) > 0 {
+				ordered = append(ordered, queue[0])
+				byGroup[name] = queue[1:]
+			}
+		}
+	}
+	return ordered
+}
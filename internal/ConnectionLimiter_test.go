@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConnectionLimiterReturnsTokenOfCancelledWait(t *testing.T) {
+	l := NewConnectionLimiter(1, 1, nil, 0)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next token is a second away: a caller cancelled meanwhile must not keep it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("Wait of a cancelled caller returned no error")
+	}
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.5 {
+		t.Errorf("tokens = %.2f after the cancelled wait, want the token given back (about 0)", tokens)
+	}
+}
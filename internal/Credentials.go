@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// CredentialRef points to the credentials of a device or group without holding the secret itself.
+type CredentialRef struct {
+	Username        string `yaml:"username,omitempty"`
+	PasswordEnv     string `yaml:"password_env,omitempty"`     // Environment variable holding the password
+	PasswordFile    string `yaml:"password_file,omitempty"`    // File holding the password on its first line
+	PasswordKeyring string `yaml:"password_keyring,omitempty"` // Account name of a "port-audit" entry in the OS keyring
+	KeyFile         string `yaml:"key_file,omitempty"`         // Private key file
+}
+
+var (
+	secretMu    sync.Mutex
+	secretCache = make(map[string]string) // Resolved passwords by source, so a keyring or file is read once per run
+)
+
+/*
+Resolve the credentials used to authenticate to a device.
+
+Parameters:
+  - device Device: The device, with its credentials already merged with those of its group (see ReadInventory).
+  - cfg *Config: The run configuration holding the command-line credentials used as a fallback.
+
+Returns:
+  - AuthOptions: The username, password, key and agent settings for the device.
+  - error: Returned if a referenced password cannot be read.
+
+Each setting is taken from the device credentials, then its group, then the command line.
+*/
+
+func ResolveCredentials(device Device, cfg *Config) (AuthOptions, error) {
+	opts := AuthOptions{
+		Username: cfg.Username,
+		Password: cfg.Password,
+		KeyFile:  cfg.KeyFile,
+		UseAgent: cfg.UseAgent || device.Agent,
+	}
+
+	if ref := device.Credentials; ref != nil {
+		if ref.Username != "" {
+			opts.Username = ref.Username
+		}
+		if ref.KeyFile != "" {
+			opts.KeyFile = ref.KeyFile
+		}
+		password, found, err := resolvePassword(*ref)
+		if err != nil {
+			return opts, err
+		}
+		if found {
+			opts.Password = password
+		}
+	}
+
+	// The key_file shorthand on the device takes precedence over any credential reference
+	if device.KeyFile != "" {
+		opts.KeyFile = device.KeyFile
+	}
+	return opts, nil
+}
+
+// resolvePassword reads the password from the first source set in the reference; found is false if none is set.
+func resolvePassword(ref CredentialRef) (password string, found bool, err error) {
+	var source string
+	switch {
+	case ref.PasswordEnv != "":
+		source = "env:" + ref.PasswordEnv
+	case ref.PasswordFile != "":
+		source = "file:" + ref.PasswordFile
+	case ref.PasswordKeyring != "":
+		source = "keyring:" + ref.PasswordKeyring
+	default:
+		return "", false, nil
+	}
+
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	if password, ok := secretCache[source]; ok {
+		return password, true, nil
+	}
+
+	switch {
+	case ref.PasswordEnv != "":
+		password = os.Getenv(ref.PasswordEnv)
+		if password == "" {
+			return "", false, fmt.Errorf("password environment variable %s is not set", ref.PasswordEnv)
+		}
+	case ref.PasswordFile != "":
+		password, err = readPasswordFile(ref.PasswordFile)
+	default:
+		password, err = readKeyring(ref.PasswordKeyring)
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	secretCache[source] = password
+	return password, true, nil
+}
+
+// readPasswordFile returns the first line of a password file.
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file %s: %v", path, err)
+	}
+	password, _, _ := strings.Cut(string(data), "\n")
+	password = strings.TrimRight(password, "\r")
+	if password == "" {
+		return "", fmt.Errorf("password file %s is empty", path)
+	}
+	return password, nil
+}
+
+// readKeyring looks up the password of an account stored under the "port-audit" service in the OS keyring.
+func readKeyring(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", "port-audit", "-a", account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", "port-audit", "account", account)
+	default:
+		return "", fmt.Errorf("keyring lookup is not supported on %s", runtime.GOOS)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read keyring entry %s: %v", account, err)
+	}
+	password := strings.TrimRight(string(output), "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("keyring entry %s is empty", account)
+	}
+	return password, nil
+}
+
+/*
+Report whether the run needs the command-line username, and the command-line password, key or agent.
+
+Parameters:
+  - inventory *Inventory: The inventory, with the group settings already applied (see ReadInventory).
+  - jumpHosts []JumpHost: The jump hosts of the devices without their own.
+
+Returns:
+  - username bool: True if at least one device or jump host has no username of its own.
+  - auth bool: True if at least one device or jump host has no authentication method of its own.
+*/
+
+func NeedsGlobalCredentials(inventory *Inventory, jumpHosts []JumpHost) (username, auth bool) {
+	for _, device := range inventory.Devices {
+		username = username || !hasOwnUsername(device.Credentials)
+		// Telnet devices can only log in with a password
+		if DeviceTransport(device) == TransportTelnet {
+			auth = auth || device.Credentials == nil || !hasPasswordSource(*device.Credentials)
+		} else {
+			auth = auth || !hasOwnAuthentication(device.Credentials, device.KeyFile, device.Agent)
+		}
+
+		hops := device.JumpHosts
+		if len(hops) == 0 {
+			hops = jumpHosts
+		}
+		for _, hop := range hops {
+			username = username || !hasOwnUsername(hop.Credentials)
+			auth = auth || !hasOwnAuthentication(hop.Credentials, "", hop.Agent)
+		}
+	}
+	return username, auth
+}
+
+// hasOwnUsername reports whether the reference sets the username.
+func hasOwnUsername(ref *CredentialRef) bool {
+	return ref != nil && ref.Username != ""
+}
+
+// hasOwnAuthentication reports whether a password source, a private key or the SSH agent is set outside the command line.
+func hasOwnAuthentication(ref *CredentialRef, keyFile string, agent bool) bool {
+	if keyFile != "" || agent {
+		return true
+	}
+	return ref != nil && (ref.KeyFile != "" || hasPasswordSource(*ref))
+}
+
+// hasPasswordSource reports whether the reference points to a password.
+func hasPasswordSource(ref CredentialRef) bool {
+	return ref.PasswordEnv != "" || ref.PasswordFile != "" || ref.PasswordKeyring != ""
+}
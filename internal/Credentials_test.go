@@ -0,0 +1,33 @@
+package internal
+
+import "testing"
+
+func TestNeedsGlobalCredentials(t *testing.T) {
+	withPassword := &CredentialRef{Username: "audit", PasswordEnv: "AUDIT_PASSWORD"}
+	bastion := []JumpHost{{Host: "bastion", Credentials: &CredentialRef{Username: "jump"}}}
+	tests := []struct {
+		name           string
+		devices        []Device
+		jumpHosts      []JumpHost
+		username, auth bool
+	}{
+		{"no devices", nil, nil, false, false},
+		{"key_file", []Device{{Host: "sw1", KeyFile: "~/.ssh/lab"}}, nil, true, false},
+		{"agent", []Device{{Host: "sw1", Agent: true}}, nil, true, false},
+		{"credentials key_file", []Device{{Host: "sw1", Credentials: &CredentialRef{Username: "audit", KeyFile: "~/.ssh/lab"}}}, nil, false, false},
+		{"credentials password", []Device{{Host: "sw1", Credentials: withPassword}}, nil, false, false},
+		{"username only", []Device{{Host: "sw1", Credentials: &CredentialRef{Username: "audit"}}}, nil, false, true},
+		{"one device without", []Device{{Host: "sw1", Credentials: withPassword}, {Host: "sw2"}}, nil, true, true},
+		{"telnet with key_file", []Device{{Host: "sw1", Transport: TransportTelnet, KeyFile: "~/.ssh/lab"}}, nil, true, true},
+		{"telnet with password", []Device{{Host: "sw1", Transport: TransportTelnet, Credentials: withPassword}}, nil, false, false},
+		{"global jump host without", []Device{{Host: "sw1", Credentials: withPassword}}, bastion, false, true},
+		{"jump host without username", []Device{{Host: "sw1", Credentials: withPassword, JumpHosts: []JumpHost{{Host: "bastion", Agent: true}}}}, nil, true, false},
+		{"own jump host with agent", []Device{{Host: "sw1", Agent: true, JumpHosts: []JumpHost{{Host: "bastion", Agent: true}}}}, bastion, true, false},
+	}
+	for _, test := range tests {
+		username, auth := NeedsGlobalCredentials(&Inventory{Devices: test.devices}, test.jumpHosts)
+		if username != test.username || auth != test.auth {
+			t.Errorf("%s: NeedsGlobalCredentials = %t, %t, want %t, %t", test.name, username, auth, test.username, test.auth)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package internal
+
+import "time"
+
+// CommandOutput holds the raw output of one command run on a device.
+type CommandOutput struct {
+	Command  string
+	Output   string
+	Duration time.Duration
+	Err      error // Set if the command was rejected or did not complete; Output may then be partial
+}
+
+/*
+DeviceResult is everything collected from one device during a run: the parsed rows, the raw output of each command,
+the timings and the final error. ProcessDevice returns one result per device and the results are aggregated once all
+workers have finished, so nothing is shared between the workers while they run.
+*/
+type DeviceResult struct {
+	Device  Device
+	Status  DeviceStatus    // Outcome, failure class, attempts and duration
+	Rows    []InterfaceData // Interface data merged across the commands of the last attempt
+	Outputs []CommandOutput // Raw output of the commands of the last attempt, in the order they were run
+	Started time.Time
+	Err     error // Final error of the device, nil when it was audited completely
+}
+
+// ReportRows returns the rows of the device for the audit sheet: its interface data, or the "not collected" marker.
+func (r DeviceResult) ReportRows() []InterfaceData {
+	if len(r.Rows) == 0 {
+		return []InterfaceData{NotCollectedRow(r.Device.Host)}
+	}
+	return r.Rows
+}
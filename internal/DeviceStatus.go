@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// Device audit outcomes.
+const (
+	StatusOK      = "ok"      // All commands ran and interface data was collected
+	StatusPartial = "partial" // Interface data was collected but a command failed
+	StatusFailed  = "failed"  // No interface data was collected
+
+	StatusNotCollected = "not collected" // The run was cancelled before the device was audited
+)
+
+// DeviceStatus records the outcome of auditing one device.
+type DeviceStatus struct {
+	Host       string        `json:"host"`
+	Platform   string        `json:"platform"`
+	Transport  string        `json:"transport"`
+	Status     string        `json:"status"`
+	Class      FailureClass  `json:"failure_class,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Attempts   int           `json:"attempts"`
+	Interfaces int           `json:"interfaces"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// NotCollectedRow returns the row marking a device without interface data in the audit sheet and its report.
+func NotCollectedRow(host string) InterfaceData {
+	return InterfaceData{Node: host, Status: StatusNotCollected, Description: "Device not collected"}
+}
+
+// isNotCollectedRow reports whether a sheet row is the marker of a device without interface data.
+func isNotCollectedRow(d InterfaceData) bool {
+	return d.Status == StatusNotCollected && d.Slot == "" && d.Port == ""
+}
+
+/*
+Write the per-device outcomes of the run to a JSON file for scripts and monitoring.
+
+Parameters:
+  - path string: The path of the results file.
+  - statuses []DeviceStatus: The outcome of every device of the inventory.
+
+Returns:
+  - error: Returned if the file cannot be written.
+*/
+
+func WriteResultsFile(path string, statuses []DeviceStatus) error {
+	sorted := append([]DeviceStatus(nil), statuses...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Host < sorted[j].Host })
+
+	summary := make(map[string]int)
+	for _, status := range sorted {
+		summary[status.Status]++
+	}
+
+	data, err := json.MarshalIndent(struct {
+		GeneratedAt time.Time      `json:"generated_at"`
+		Summary     map[string]int `json:"summary"`
+		Devices     []DeviceStatus `json:"devices"`
+	}{time.Now(), summary, sorted}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write results file %s: %v", path, err)
+	}
+	log.Printf("Per-device results written to %s", path)
+	return nil
+}
@@ -14,19 +14,19 @@ Decide whether to create a new Excel file or update an existing one based on the
 
 Parameters:
   allData []InterfaceData - A slice containing all the data to be written to or updated in the Excel file.
-  baseFile bool - A boolean flag that determines the operation:
-                  true to create a new Excel file, false to update an existing file.
+  cfg *Config - The run configuration: cfg.BaseFile selects the operation (true to create a new Excel file, false to
+                update an existing file), cfg.RunID names the audit sheet and cfg.Compare is the comparison policy.
 
 This function does not return any value but will halt execution and log a fatal error if any step fails.
 */
 
-func ExcelOperations(allData []InterfaceData, baseFile bool, logger *pterm.Logger) {
+func ExcelOperations(allData []InterfaceData, cfg *Config, logger *pterm.Logger) {
 	var err error
-	if baseFile {
+	if cfg.BaseFile {
 		err = CreateExcel(allData, filename, logger)
 		logger.Info("Creating 'Baseline' Excel file", logger.Args("File name", filename))
 	} else {
-		err = UpdateExcel(allData, filename, logger)
+		err = UpdateExcel(allData, filename, cfg.RunID, logger)
 		//logger.Info("Working on existing Excel file", logger.Args("File", filename))
 	}
 	if err != nil {
@@ -36,7 +36,7 @@ func ExcelOperations(allData []InterfaceData, baseFile bool, logger *pterm.Logge
 	logger.Trace("Excel operations completed successfully.")
 
 	// Compare data in Excel sheets.
-	if err = CompareExcelSheets(filename, logger); err != nil {
+	if err = CompareExcelSheets(filename, cfg, logger); err != nil {
 		log.Fatalf("Failed during Excel sheet comparison: %v", err)
 		logger.Fatal("Failed during Excel sheet comparison: %v", logger.Args(err))
 	}
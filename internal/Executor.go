@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"log"
+	"strings"
+	"time"
+)
+
+// Execution modes (-exec-mode).
+const (
+	ExecModeAuto  = "auto"  // Use the mode registered for the platform
+	ExecModeShell = "shell" // Interactive shell on a PTY, see ShellExecutor
+	ExecModeExec  = "exec"  // One SSH exec request per command
+)
+
+// Executor runs CLI commands on a connected device and returns their output.
+type Executor interface {
+	Run(command string) (string, error)
+	Close() error
+}
+
+/*
+Connect to a device over its transport and return an executor ready to run the profile's commands.
+
+Parameters:
+  - ctx context.Context: The run context; cancelling it aborts the connection and the login.
+  - device Device: The device to connect to; Device.Transport selects ssh (default) or telnet.
+  - port int: The port of the device.
+  - opts AuthOptions: The credentials resolved for the device.
+  - profile PlatformProfile: The platform profile of the device.
+  - cfg *Config: The run configuration (host key checking, jump hosts, execution mode, command timeout).
+
+Returns:
+  - Executor: The executor for the device.
+  - func(): Closes the executor and the underlying connection.
+  - error: Returned if the transport is unknown or the connection, login or shell setup fails.
+*/
+
+func OpenExecutor(ctx context.Context, device Device, port int, opts AuthOptions, profile PlatformProfile, cfg *Config) (Executor, func(), error) {
+	// Devices behind a bastion are reached through the connection shared by all workers
+	hops := device.JumpHosts
+	if len(hops) == 0 {
+		hops = cfg.JumpHosts
+	}
+	dial, err := cfg.Bastions.Dialer(ctx, hops, cfg)
+	if err != nil {
+		return nil, nil, interrupted(ctx, err)
+	}
+
+	switch DeviceTransport(device) {
+	case TransportTelnet:
+		executor, err := DialTelnet(ctx, device.Host, port, opts, profile.PagerCommand, cfg.DialTimeout, cfg.CommandTimeout, dial)
+		if err != nil {
+			return nil, nil, err
+		}
+		return executor, func() { executor.Close() }, nil
+	case TransportSSH:
+	default:
+		return nil, nil, fmt.Errorf("unsupported transport %q (supported: %s, %s)", device.Transport, TransportSSH, TransportTelnet)
+	}
+
+	auth, err := BuildAuthMethods(opts, cfg.Interactive)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := InitialiseConnection(ctx, device.Host, port, opts.Username, auth, cfg.HostKeyCallback, cfg.DialTimeout, dial)
+	if err != nil {
+		return nil, nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { client.Close() }) // Abort the shell setup when the run is cancelled
+	executor, err := NewExecutor(client, profile, cfg.ExecMode, cfg.CommandTimeout)
+	stop()
+	if err != nil {
+		client.Close()
+		return nil, nil, interrupted(ctx, err)
+	}
+	return executor, func() {
+		executor.Close()
+		client.Close()
+	}, nil
+}
+
+// DeviceTransport returns the transport of a device in lower case, defaulting to ssh.
+func DeviceTransport(device Device) string {
+	if transport := strings.ToLower(strings.TrimSpace(device.Transport)); transport != "" {
+		return transport
+	}
+	return TransportSSH
+}
+
+/*
+Create the executor for a device connection according to the execution mode of the run and the device's platform.
+
+Parameters:
+  - client *ssh.Client: The SSH client connected to the device.
+  - profile PlatformProfile: The platform profile, holding the default mode and the pager command.
+  - mode string: The execution mode of the run (auto, shell or exec).
+  - timeout time.Duration: How long a single command may run before it is abandoned.
+
+Returns:
+  - Executor: The executor ready to run commands.
+  - error: Returned if the shell cannot be started or the device prompt is not detected.
+*/
+
+func NewExecutor(client *ssh.Client, profile PlatformProfile, mode string, timeout time.Duration) (Executor, error) {
+	switch mode {
+	case ExecModeShell:
+	case ExecModeExec:
+		return &ExecExecutor{client: client}, nil
+	case ExecModeAuto, "":
+		if !profile.ShellMode {
+			return &ExecExecutor{client: client}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown execution mode %q", mode)
+	}
+
+	log.Printf("Starting interactive shell (platform %s)", profile.Name)
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+	executor, err := NewShellExecutor(session, profile.PagerCommand, timeout)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return executor, nil
+}
+
+// ExecExecutor runs each command with an SSH exec request on a new session of the same client,
+// as supported by most NX-OS and IOS-XR images.
+type ExecExecutor struct {
+	client *ssh.Client
+}
+
+func (e *ExecExecutor) Run(command string) (string, error) {
+	session, err := e.client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput(command)
+	return string(output), err
+}
+
+// Close is a no-op: sessions are closed after each command and the client is owned by the caller.
+func (e *ExecExecutor) Close() error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// FailureClass sorts the reason a device could not be audited.
+type FailureClass string
+
+const (
+	FailureNone            FailureClass = ""
+	FailureDNS             FailureClass = "dns"              // Host name could not be resolved
+	FailureRefused         FailureClass = "tcp-refused"      // Nothing listening on the port
+	FailureTimeout         FailureClass = "timeout"          // Connection, login or command timed out
+	FailureAuth            FailureClass = "auth"             // Credentials rejected
+	FailureHostKey         FailureClass = "host-key"         // Host key unknown or changed
+	FailureCommandRejected FailureClass = "command-rejected" // Device rejected or failed to run a command
+	FailureNoRows          FailureClass = "no-rows"          // Commands ran but the parsers produced no interface data
+	FailureCancelled       FailureClass = "cancelled"        // Run interrupted or its deadline reached before the device completed
+	FailureOther           FailureClass = "other"            // Configuration or unexpected errors
+)
+
+// Retryable reports whether a failure of this class may succeed on a later attempt.
+// Authentication failures are never retried to avoid locking out the account.
+func (c FailureClass) Retryable() bool {
+	return c == FailureDNS || c == FailureRefused || c == FailureTimeout
+}
+
+// CommandError reports a command that the device rejected or that did not complete.
+type CommandError struct {
+	Command string
+	Reason  string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command %q rejected: %s", e.Command, e.Reason)
+}
+
+// errNoRows reports a device whose commands all ran without producing any interface data.
+var errNoRows = errors.New("commands completed but no interface data was parsed")
+
+// errRunInterrupted marks the errors caused by the cancellation of the run (signal or -run-timeout).
+var errRunInterrupted = errors.New("run interrupted")
+
+// interrupted attributes an error to the cancellation of the run once its context is done, so it is not retried.
+func interrupted(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, errRunInterrupted) {
+		return err
+	}
+	return fmt.Errorf("%w (%v): %v", errRunInterrupted, context.Cause(ctx), err)
+}
+
+// rejectedPattern matches the error messages printed by Cisco CLIs for commands they cannot run.
+var rejectedPattern = regexp.MustCompile(`(?m)^\s*(% ?(Invalid input|Incomplete command|Ambiguous command|Unknown command|Invalid command).*)$`)
+
+// checkCommandOutput returns a CommandError if the output shows that the device rejected the command.
+func checkCommandOutput(command, output string) error {
+	if matches := rejectedPattern.FindStringSubmatch(output); matches != nil {
+		return &CommandError{Command: command, Reason: strings.TrimSpace(matches[1])}
+	}
+	return nil
+}
+
+// ClassifyError sorts an error returned while auditing a device into a failure class.
+func ClassifyError(err error) FailureClass {
+	if err == nil {
+		return FailureNone
+	}
+
+	var hostKeyErr *HostKeyError
+	var commandErr *CommandError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	message := strings.ToLower(err.Error())
+
+	switch {
+	case errors.Is(err, errRunInterrupted):
+		return FailureCancelled
+	case errors.As(err, &hostKeyErr):
+		return FailureHostKey
+	case errors.As(err, &commandErr):
+		if strings.Contains(strings.ToLower(commandErr.Reason), "timed out") {
+			return FailureTimeout
+		}
+		return FailureCommandRejected
+	case errors.As(err, &dnsErr):
+		return FailureDNS
+	case errors.Is(err, syscall.ECONNREFUSED), strings.Contains(message, "connection refused"):
+		return FailureRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout(), strings.Contains(message, "timed out"), strings.Contains(message, "i/o timeout"):
+		return FailureTimeout
+	case strings.Contains(message, "unable to authenticate"), strings.Contains(message, "authentication failed"):
+		return FailureAuth
+	}
+	return FailureOther
+}
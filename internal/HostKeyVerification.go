@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Host key verification modes (-host-key).
+const (
+	HostKeyStrict   = "strict"   // Only accept keys already present in a known_hosts file
+	HostKeyTOFU     = "tofu"     // Trust on first use: record unknown keys, reject changed keys
+	HostKeyInsecure = "insecure" // Skip host key verification entirely
+)
+
+// HostKeyError reports a host key that could not be verified for a device.
+type HostKeyError struct {
+	Host   string
+	Reason string
+}
+
+func (e *HostKeyError) Error() string {
+	return fmt.Sprintf("host key verification failed for %s: %s", e.Host, e.Reason)
+}
+
+/*
+Build the SSH host key callback for the configured verification mode.
+
+Parameters:
+  - mode string: One of strict, tofu or insecure.
+  - knownHostsFile string: The port-audit known_hosts file; new keys are recorded here in tofu mode.
+
+Returns:
+  - ssh.HostKeyCallback: The callback to use in every SSH client configuration.
+  - error: Returned if the mode is unknown or the known_hosts files cannot be loaded.
+
+Keys are checked against the user's ~/.ssh/known_hosts (when present) and the port-audit known_hosts file.
+A key that differs from a recorded one is always rejected, in both strict and tofu modes.
+*/
+
+func NewHostKeyCallback(mode, knownHostsFile string) (ssh.HostKeyCallback, error) {
+	switch mode {
+	case HostKeyInsecure:
+		log.Printf("WARNING: Host key verification is disabled, devices are not authenticated")
+		return ssh.InsecureIgnoreHostKey(), nil
+	case HostKeyStrict, HostKeyTOFU:
+	default:
+		return nil, fmt.Errorf("unknown host key mode %q (supported: %s, %s, %s)", mode, HostKeyStrict, HostKeyTOFU, HostKeyInsecure)
+	}
+
+	// In tofu mode the port-audit known_hosts file is created so new keys can be recorded
+	if mode == HostKeyTOFU {
+		file, err := os.OpenFile(knownHostsFile, os.O_CREATE|os.O_RDONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create known_hosts file %s: %v", knownHostsFile, err)
+		}
+		file.Close()
+	}
+
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		userFile := filepath.Join(home, ".ssh", "known_hosts")
+		if _, err := os.Stat(userFile); err == nil {
+			files = append(files, userFile)
+		}
+	}
+	if _, err := os.Stat(knownHostsFile); err == nil {
+		files = append(files, knownHostsFile)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("strict host key checking requires a known_hosts file (~/.ssh/known_hosts or %s)", knownHostsFile)
+	}
+
+	check, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts files: %v", err)
+	}
+	log.Printf("Host key verification mode %s using: %v", mode, files)
+
+	verifier := &hostKeyVerifier{
+		mode:    mode,
+		file:    knownHostsFile,
+		check:   check,
+		trusted: make(map[string][]byte),
+	}
+	return verifier.verify, nil
+}
+
+// hostKeyVerifier checks host keys against known_hosts and records new keys in tofu mode.
+type hostKeyVerifier struct {
+	mode    string
+	file    string
+	check   ssh.HostKeyCallback
+	mu      sync.Mutex
+	trusted map[string][]byte // Keys accepted on first use during this run
+}
+
+func (v *hostKeyVerifier) verify(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	err := v.check(hostname, remote, key)
+	if err == nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return &HostKeyError{Host: hostname, Reason: err.Error()}
+	}
+	if len(keyErr.Want) > 0 {
+		return &HostKeyError{Host: hostname, Reason: fmt.Sprintf("key mismatch, got %s %s (possible impersonation)", key.Type(), ssh.FingerprintSHA256(key))}
+	}
+	if v.mode != HostKeyTOFU {
+		return &HostKeyError{Host: hostname, Reason: fmt.Sprintf("unknown host key %s %s", key.Type(), ssh.FingerprintSHA256(key))}
+	}
+
+	// Trust on first use: record the key so later runs verify against it
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	normalised := knownhosts.Normalize(hostname)
+	if known, ok := v.trusted[normalised]; ok {
+		if bytes.Equal(known, key.Marshal()) {
+			return nil
+		}
+		return &HostKeyError{Host: hostname, Reason: fmt.Sprintf("key mismatch, got %s %s (possible impersonation)", key.Type(), ssh.FingerprintSHA256(key))}
+	}
+
+	file, err := os.OpenFile(v.file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return &HostKeyError{Host: hostname, Reason: fmt.Sprintf("failed to record new host key: %v", err)}
+	}
+	defer file.Close()
+	if _, err := file.WriteString(knownhosts.Line([]string{normalised}, key) + "\n"); err != nil {
+		return &HostKeyError{Host: hostname, Reason: fmt.Sprintf("failed to record new host key: %v", err)}
+	}
+	v.trusted[normalised] = key.Marshal()
+	log.Printf("Trusted new host key for %s on first use: %s %s", hostname, key.Type(), ssh.FingerprintSHA256(key))
+	return nil
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"golang.org/x/crypto/ssh"
 	"log"
@@ -9,46 +10,62 @@ import (
 )
 
 /*
-Set up and return an SSH session for the specified network device.
+Set up and return an SSH client connection for the specified network device.
+The client is reused for every command run on the device (see NewExecutor).
 Parameters:
+  - ctx context.Context: The run context; cancelling it aborts the connection attempt.
   - host string: The IP address or hostname of the network device.
   - port int: The port number to connect to on the network device for SSH.
   - username string: The username for SSH authentication.
-  - password string: The password for SSH authentication.
+  - auth []ssh.AuthMethod: The authentication methods to try, in order (see BuildAuthMethods).
+  - hostKeyCallback ssh.HostKeyCallback: Verifies the device host key (see NewHostKeyCallback).
+  - timeout time.Duration: Maximum time to establish the connection, including the SSH handshake.
+  - dial DialFunc: Opens the connection through a jump host (see BastionPool); nil dials the device directly.
 
 Returns:
-  - *ssh.Session: A pointer to an ssh.Session which can be used to execute commands on the connected device.
-  - error: An error is returned if the connection or session setup fails. The error provides details about the failure.
+  - *ssh.Client: The connected client, able to open as many sessions as needed. The caller must close it.
+  - error: An error is returned if the connection setup fails. The error provides details about the failure.
 
 
 Execution Flow:
   - The function attempts to dial an SSH connection using the provided configuration. If it fails, it logs and returns
     an error detailing the connection issue.
-  - On a successful connection, it attempts to create an SSH session. If session creation fails, it logs the error,
-    closes the client connection, and returns an error.
-  - If all steps are successful, it returns the created session ready for command execution.
+  - On a successful connection, it returns the client ready for opening command sessions.
 */
 
-func InitialiseConnection(host string, port int, username, password string) (*ssh.Session, error) {
+func InitialiseConnection(ctx context.Context, host string, port int, username string, auth []ssh.AuthMethod, hostKeyCallback ssh.HostKeyCallback, timeout time.Duration, dial DialFunc) (*ssh.Client, error) {
 	config := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{ssh.Password(password)},
-		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil // Skip host key verification
-		},
-		Timeout: 5 * time.Second,
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
 	}
 	log.Printf("Attempting SSH connection to %s:%d with user %s", host, port, username)
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host, port), config)
+	address := fmt.Sprintf("%s:%d", host, port)
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: timeout}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", address)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to dial SSH to %s:%d: %v", host, port, err)
+		return nil, interrupted(ctx, fmt.Errorf("Failed to dial SSH to %s:%d: %w", host, port, err))
 	}
 
-	session, err := client.NewSession()
+	// Tunnelled connections have no deadlines, so the handshake is bounded with a timer and by the run context
+	timer := time.AfterFunc(config.Timeout, func() { conn.Close() })
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
+	stop()
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		err = interrupted(ctx, err)
+	case !timer.Stop():
+		err = fmt.Errorf("handshake timed out after %s: %w", config.Timeout, err)
+	}
+	timer.Stop()
 	if err != nil {
-		client.Close()
-		return nil, fmt.Errorf("Failed to create session for %s: %v", host, err)
+		conn.Close()
+		return nil, fmt.Errorf("Failed to dial SSH to %s:%d: %w", host, port, err)
 	}
-
-	return session, nil
+	return ssh.NewClient(clientConn, channels, requests), nil
 }
@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// InterfaceType is an entry of the interface type normalisation table.
+type InterfaceType struct {
+	Long    string   // Full name, e.g. "GigabitEthernet"
+	Short   string   // Abbreviation printed by 'show interface status', e.g. "Gi"
+	Aliases []string // Other spellings found in the output of the supported platforms
+}
+
+// interfaceTypes is the normalisation table of the interface types of IOS, IOS-XE, NX-OS and IOS-XR. Names are
+// matched case-insensitively against Long, Short and Aliases.
+var interfaceTypes = []InterfaceType{
+	{Long: "FastEthernet", Short: "Fa"},
+	{Long: "GigabitEthernet", Short: "Gi", Aliases: []string{"Gig", "GigE", "Ge"}},
+	{Long: "TwoGigabitEthernet", Short: "Tw"},
+	{Long: "FiveGigabitEthernet", Short: "Fi"},
+	{Long: "TenGigabitEthernet", Short: "Te", Aliases: []string{"TenGigE", "TenGig", "Ten"}},
+	{Long: "TwentyFiveGigE", Short: "Twe", Aliases: []string{"TwentyFiveGigabitEthernet"}},
+	{Long: "FortyGigabitEthernet", Short: "Fo", Aliases: []string{"FortyGigE"}},
+	{Long: "HundredGigE", Short: "Hu", Aliases: []string{"HundredGigabitEthernet"}},
+	{Long: "FourHundredGigE", Short: "FH", Aliases: []string{"FourHundredGigabitEthernet"}},
+	{Long: "AppGigabitEthernet", Short: "Ap"},
+	{Long: "Ethernet", Short: "Eth", Aliases: []string{"Et"}},
+	{Long: "Port-channel", Short: "Po", Aliases: []string{"Portchannel"}},
+	{Long: "Bundle-Ether", Short: "BE"},
+	{Long: "Vlan", Short: "Vl"},
+	{Long: "Loopback", Short: "Lo"},
+	{Long: "Tunnel", Short: "Tu"},
+	{Long: "Serial", Short: "Se"},
+	{Long: "mgmt", Short: "mgmt"},
+	{Long: "MgmtEth", Short: "Mg"},
+	{Long: "nve", Short: "nve"},
+}
+
+// interfaceTypeIndex maps every lower-case spelling of the normalisation table to its entry.
+var interfaceTypeIndex = func() map[string]*InterfaceType {
+	index := make(map[string]*InterfaceType)
+	for i := range interfaceTypes {
+		t := &interfaceTypes[i]
+		for _, name := range append([]string{t.Long, t.Short}, t.Aliases...) {
+			index[strings.ToLower(name)] = t
+		}
+	}
+	return index
+}()
+
+// interfaceNamePattern splits an interface name into its type, its numeric path and its subinterface number.
+var interfaceNamePattern = regexp.MustCompile(`^([A-Za-z][A-Za-z-]*?)\s*(\d+(?:/\d+)*)(?:\.(\d+))?$`)
+
+/*
+InterfaceName is the structured form of an interface name such as "GigabitEthernet1/0/24", "Te0/0/0/1", "Eth1/1/2",
+"Po10" or "Gi1/0/1.100".
+
+The numbers of the name are assigned to positions according to their count and the interface type:
+  - 1 number: Port (Po10, Vlan100, Lo0, mgmt0)
+  - 2 numbers: Slot/Port (Gi1/24, Eth1/1)
+  - 3 numbers: Slot/Subslot/Port (IOS and IOS-XE stacks and routers, Gi1/0/24); on Ethernet (NX-OS), Slot/Port/Breakout
+    (Eth1/1/2), or Chassis/Slot/Port for a FEX numbered from 100 (Eth101/1/1)
+  - 4 numbers: Chassis/Slot/Module/Port, the IOS-XR rack/slot/module/port (Te0/0/0/1)
+  - 5 numbers: Chassis/Slot/Module/Port/Breakout (IOS-XR breakouts, Hu0/0/0/0/1)
+
+Names that do not follow this layout (e.g. "MgmtEth0/RP0/CPU0/0") are kept as written and only compared as a whole.
+*/
+type InterfaceName struct {
+	Type         string // Long form of the type from the normalisation table, or the type as written when unknown
+	Chassis      string
+	Module       string
+	Slot         string
+	Subslot      string
+	Port         string
+	Breakout     string
+	Subinterface string
+
+	raw    string         // Name as written
+	known  *InterfaceType // Entry of the normalisation table, nil for unknown types
+	parsed bool           // Set when the name follows the type/number layout
+}
+
+/*
+Parse an interface name as printed by the supported platforms.
+
+Parameters:
+  - name string: The interface name, long or abbreviated, e.g. "GigabitEthernet1/0/24" or "Gi1/0/24".
+
+Returns:
+  - InterfaceName: The structured name; names that cannot be parsed are kept as written.
+*/
+
+func ParseInterfaceName(name string) InterfaceName {
+	name = strings.TrimSpace(name)
+	n := InterfaceName{raw: name}
+	matches := interfaceNamePattern.FindStringSubmatch(name)
+	if matches == nil {
+		return n
+	}
+	n.parsed = true
+	n.Type = matches[1]
+	if t, ok := interfaceTypeIndex[strings.ToLower(matches[1])]; ok {
+		n.known, n.Type = t, t.Long
+	}
+	n.Subinterface = matches[3]
+
+	numbers := strings.Split(matches[2], "/")
+	var positions []*string
+	switch len(numbers) {
+	case 1:
+		positions = []*string{&n.Port}
+	case 2:
+		positions = []*string{&n.Slot, &n.Port}
+	case 3:
+		positions = []*string{&n.Slot, &n.Subslot, &n.Port}
+		if n.Type == "Ethernet" {
+			if fex, _ := strconv.Atoi(numbers[0]); fex >= 100 {
+				positions = []*string{&n.Chassis, &n.Slot, &n.Port}
+			} else {
+				positions = []*string{&n.Slot, &n.Port, &n.Breakout}
+			}
+		}
+	case 4:
+		positions = []*string{&n.Chassis, &n.Slot, &n.Module, &n.Port}
+	case 5:
+		positions = []*string{&n.Chassis, &n.Slot, &n.Module, &n.Port, &n.Breakout}
+	default:
+		n.parsed = false
+		return n
+	}
+	for i, position := range positions {
+		*position = numbers[i]
+	}
+	return n
+}
+
+// path returns the numbers of the name joined with slashes, e.g. "1/0/24".
+func (n InterfaceName) path() string {
+	var numbers []string
+	for _, number := range []string{n.Chassis, n.Slot, n.Module, n.Subslot, n.Port, n.Breakout} {
+		if number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	path := strings.Join(numbers, "/")
+	if n.Subinterface != "" {
+		path += "." + n.Subinterface
+	}
+	return path
+}
+
+// Long returns the name with the full type, e.g. "GigabitEthernet1/0/24", the canonical form of the name.
+func (n InterfaceName) Long() string {
+	if !n.parsed {
+		return n.raw
+	}
+	return n.Type + n.path()
+}
+
+// Short returns the name with the abbreviated type, e.g. "Gi1/0/24"; unknown types are kept as written.
+func (n InterfaceName) Short() string {
+	if !n.parsed {
+		return n.raw
+	}
+	if n.known != nil {
+		return n.known.Short + n.path()
+	}
+	return n.Type + n.path()
+}
+
+// Key returns the form used to match an interface across commands and sheets: "Gi1/0/24", "GigabitEthernet1/0/24"
+// and "Ge1/0/24" share the key "gi1/0/24".
+func (n InterfaceName) Key() string {
+	return strings.ToLower(strings.Join(strings.Fields(n.Short()), ""))
+}
+
+// SlotColumn returns the SLOT of the report: the positions before the port, e.g. "1/0" for Gi1/0/24 and "0/0/0" for
+// Te0/0/0/1, or "" for names without slot such as Po10.
+func (n InterfaceName) SlotColumn() string {
+	var numbers []string
+	for _, number := range []string{n.Chassis, n.Slot, n.Module, n.Subslot} {
+		if number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	return strings.Join(numbers, "/")
+}
+
+// PortColumn returns the PORT of the report: the port with its breakout and subinterface, e.g. "24", "1/2" for
+// Eth1/1/2 and "1.100" for Gi1/0/1.100.
+func (n InterfaceName) PortColumn() string {
+	port := n.Port
+	if n.Breakout != "" {
+		port += "/" + n.Breakout
+	}
+	if n.Subinterface != "" {
+		port += "." + n.Subinterface
+	}
+	return port
+}
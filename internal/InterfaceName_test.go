@@ -0,0 +1,79 @@
+package internal
+
+import "testing"
+
+func TestInterfaceNameNormalisation(t *testing.T) {
+	tests := []struct {
+		name, long, short, slot, port string
+	}{
+		{"Gi1/0/1", "GigabitEthernet1/0/1", "Gi1/0/1", "1/0", "1"},
+		{"Ge1/0/1", "GigabitEthernet1/0/1", "Gi1/0/1", "1/0", "1"},
+		{"GigabitEthernet1/0/1", "GigabitEthernet1/0/1", "Gi1/0/1", "1/0", "1"},
+		{"gi1/0/1", "GigabitEthernet1/0/1", "Gi1/0/1", "1/0", "1"},
+		{"Gi 1/0/1", "GigabitEthernet1/0/1", "Gi1/0/1", "1/0", "1"},
+		{"Te1/1/1", "TenGigabitEthernet1/1/1", "Te1/1/1", "1/1", "1"},
+		{"TenGigE1/1/1", "TenGigabitEthernet1/1/1", "Te1/1/1", "1/1", "1"},
+		{"TenGigabitEthernet1/1/1", "TenGigabitEthernet1/1/1", "Te1/1/1", "1/1", "1"},
+		{"Eth1/1", "Ethernet1/1", "Eth1/1", "1", "1"},
+		{"Ethernet1/1", "Ethernet1/1", "Eth1/1", "1", "1"},
+		{"Po10", "Port-channel10", "Po10", "", "10"},
+		{"Port-channel10", "Port-channel10", "Po10", "", "10"},
+		{"Vlan100", "Vlan100", "Vl100", "", "100"},
+		{"Gi1/0/1.100", "GigabitEthernet1/0/1.100", "Gi1/0/1.100", "1/0", "1.100"},
+		{"Eth1/1/2", "Ethernet1/1/2", "Eth1/1/2", "1", "1/2"}, // NX-OS breakout port 2 of Eth1/1
+		{"Te0/0/0/1", "TenGigabitEthernet0/0/0/1", "Te0/0/0/1", "0/0/0", "1"},
+		{"TenGigE0/0/0/1", "TenGigabitEthernet0/0/0/1", "Te0/0/0/1", "0/0/0", "1"},
+		{"Tw1/0/1", "TwoGigabitEthernet1/0/1", "Tw1/0/1", "1/0", "1"},
+		{"Twe1/0/1", "TwentyFiveGigE1/0/1", "Twe1/0/1", "1/0", "1"},
+		{"TwentyFiveGigE1/0/1", "TwentyFiveGigE1/0/1", "Twe1/0/1", "1/0", "1"},
+	}
+	for _, test := range tests {
+		name := ParseInterfaceName(test.name)
+		if got := name.Long(); got != test.long {
+			t.Errorf("%s: Long() = %q, want %q", test.name, got, test.long)
+		}
+		if got := name.Short(); got != test.short {
+			t.Errorf("%s: Short() = %q, want %q", test.name, got, test.short)
+		}
+		if got, want := name.Key(), ParseInterfaceName(test.long).Key(); got != want {
+			t.Errorf("%s: Key() = %q, want the key of %s, %q", test.name, got, test.long, want)
+		}
+		if got := name.SlotColumn(); got != test.slot {
+			t.Errorf("%s: SlotColumn() = %q, want %q", test.name, got, test.slot)
+		}
+		if got := name.PortColumn(); got != test.port {
+			t.Errorf("%s: PortColumn() = %q, want %q", test.name, got, test.port)
+		}
+	}
+
+	// Tw and Twe are different types, as are the members of a stack
+	for _, pair := range [][2]string{{"Tw1/0/1", "Twe1/0/1"}, {"Gi1/0/1", "Gi2/0/1"}, {"Gi1/0/1", "Gi1/0/1.100"}} {
+		if ParseInterfaceName(pair[0]).Key() == ParseInterfaceName(pair[1]).Key() {
+			t.Errorf("%s and %s have the same key", pair[0], pair[1])
+		}
+	}
+}
+
+func TestNormalizeInterfaceNames(t *testing.T) {
+	rows := []InterfaceData{
+		{Node: "sw1", Interface: "Gi1/0/24", Slot: "1", Port: "0/24"},
+		{Node: "sw1", Interface: "Te0/0/0/1"},
+		{Node: "sw2", Interface: "", Slot: "1/0", Port: "3"}, // Sheet without Interface column
+		{Node: "sw3", Interface: "unknown-port"},
+	}
+	want := []InterfaceData{
+		{Node: "sw1", Interface: "GigabitEthernet1/0/24", Slot: "1/0", Port: "24"},
+		{Node: "sw1", Interface: "TenGigabitEthernet0/0/0/1", Slot: "0/0/0", Port: "1"},
+		{Node: "sw2", Interface: "", Slot: "1/0", Port: "3"},
+		{Node: "sw3", Interface: "unknown-port"},
+	}
+	normalized := NormalizeInterfaceNames(rows)
+	for i := range want {
+		if normalized[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, normalized[i], want[i])
+		}
+	}
+	if rows[0].Interface != "Gi1/0/24" {
+		t.Error("NormalizeInterfaceNames modified its input")
+	}
+}
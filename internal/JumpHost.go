@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JumpHost is an SSH bastion used to reach devices that are not directly reachable.
+type JumpHost struct {
+	Host        string         `yaml:"host"`
+	Port        string         `yaml:"port,omitempty"` // Defaults to 22
+	Credentials *CredentialRef `yaml:"credentials,omitempty"`
+	Agent       bool           `yaml:"agent,omitempty"`
+}
+
+// DialFunc opens a network connection, either directly or tunnelled through a bastion.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+/*
+Parse a ProxyJump style list of hops ("user@host:port,host2") as given with the -jump flag.
+
+Parameters:
+  - spec string: Comma-separated hops; the user and port are optional.
+
+Returns:
+  - []JumpHost: The hops in the order they are traversed.
+  - error: Returned if a hop has an invalid port.
+*/
+
+func ParseJumpHosts(spec string) ([]JumpHost, error) {
+	var hops []JumpHost
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		hop := JumpHost{Port: "22"}
+		if user, rest, found := strings.Cut(part, "@"); found {
+			hop.Credentials = &CredentialRef{Username: user}
+			part = rest
+		}
+		hop.Host = part
+		if host, port, err := net.SplitHostPort(part); err == nil {
+			if _, err := strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("invalid port in jump host %q", part)
+			}
+			hop.Host, hop.Port = host, port
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+// BastionPool keeps one SSH client per chain of jump hosts, shared by all workers of the run.
+type BastionPool struct {
+	mu      sync.Mutex
+	clients map[string]*bastionEntry
+}
+
+type bastionEntry struct {
+	once   sync.Once
+	client *ssh.Client
+	err    error
+}
+
+// NewBastionPool creates an empty pool; connections are opened on first use.
+func NewBastionPool() *BastionPool {
+	return &BastionPool{clients: make(map[string]*bastionEntry)}
+}
+
+/*
+Return the dial function for reaching a device through a chain of jump hosts.
+
+Parameters:
+  - ctx context.Context: The run context; cancelling it aborts connecting the chain.
+  - hops []JumpHost: The jump hosts in the order they are traversed; a direct dial is returned when empty.
+  - cfg *Config: The run configuration, used for host key checking and as the credential fallback of every hop.
+
+Returns:
+  - DialFunc: Opens connections from the last hop of the chain.
+  - error: Returned if a hop cannot be connected or authenticated.
+*/
+
+func (p *BastionPool) Dialer(ctx context.Context, hops []JumpHost, cfg *Config) (DialFunc, error) {
+	if len(hops) == 0 {
+		return nil, nil
+	}
+	client, err := p.client(ctx, hops, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return client.DialContext, nil
+}
+
+// client returns the shared client of the last hop, connecting the chain on first use.
+func (p *BastionPool) client(ctx context.Context, hops []JumpHost, cfg *Config) (*ssh.Client, error) {
+	key := chainKey(hops)
+
+	p.mu.Lock()
+	entry, ok := p.clients[key]
+	if !ok {
+		entry = &bastionEntry{}
+		p.clients[key] = entry
+	}
+	p.mu.Unlock()
+
+	entry.once.Do(func() {
+		var dial DialFunc
+		if len(hops) > 1 {
+			previous, err := p.client(ctx, hops[:len(hops)-1], cfg)
+			if err != nil {
+				entry.err = err
+				return
+			}
+			dial = previous.DialContext
+		}
+		entry.client, entry.err = dialJumpHost(ctx, hops[len(hops)-1], cfg, dial)
+		if entry.err == nil {
+			// A bastion that drops mid-run is redialled by the next device instead of failing every later device
+			go func() {
+				entry.client.Wait()
+				log.Printf("Connection to jump host %s closed", key)
+				p.remove(key, entry)
+			}()
+		}
+	})
+
+	if entry.err != nil {
+		p.remove(key, entry) // Allow a later attempt to reconnect
+	}
+	return entry.client, entry.err
+}
+
+// remove drops an entry from the pool, unless it was already replaced by a new connection.
+func (p *BastionPool) remove(key string, entry *bastionEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.clients[key] == entry {
+		delete(p.clients, key)
+	}
+}
+
+// dialJumpHost connects and authenticates to a single hop.
+func dialJumpHost(ctx context.Context, hop JumpHost, cfg *Config, dial DialFunc) (*ssh.Client, error) {
+	opts, err := ResolveCredentials(Device{Host: hop.Host, Credentials: hop.Credentials, Agent: hop.Agent}, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("jump host %s: %v", hop.Host, err)
+	}
+	auth, err := BuildAuthMethods(opts, cfg.Interactive)
+	if err != nil {
+		return nil, fmt.Errorf("jump host %s: %v", hop.Host, err)
+	}
+	port, err := strconv.Atoi(hop.port())
+	if err != nil {
+		return nil, fmt.Errorf("jump host %s: invalid port %q", hop.Host, hop.Port)
+	}
+
+	log.Printf("Connecting to jump host %s:%d", hop.Host, port)
+	client, err := InitialiseConnection(ctx, hop.Host, port, opts.Username, auth, cfg.HostKeyCallback, cfg.DialTimeout, dial)
+	if err != nil {
+		return nil, fmt.Errorf("jump host %s: %w", hop.Host, err)
+	}
+	return client, nil
+}
+
+// Close closes every bastion connection of the pool.
+func (p *BastionPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, entry := range p.clients {
+		if entry.client != nil {
+			entry.client.Close()
+		}
+		delete(p.clients, key)
+	}
+}
+
+// chainKey identifies a chain of jump hosts by its hops and users.
+func chainKey(hops []JumpHost) string {
+	parts := make([]string, len(hops))
+	for i, hop := range hops {
+		user := ""
+		if hop.Credentials != nil {
+			user = hop.Credentials.Username
+		}
+		parts[i] = fmt.Sprintf("%s@%s:%s", user, hop.Host, hop.port())
+	}
+	return strings.Join(parts, ">")
+}
+
+// port returns the SSH port of the hop, 22 when none is set, so a hop has the same key with and without explicit port.
+func (hop JumpHost) port() string {
+	if hop.Port == "" {
+		return "22"
+	}
+	return hop.Port
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBastion accepts SSH connections with any password and keeps them until they are dropped.
+type fakeBastion struct {
+	port  string
+	mu    sync.Mutex
+	conns []*ssh.ServerConn
+}
+
+func newFakeBastion(t *testing.T) *fakeBastion {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, nil }}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	bastion := &fakeBastion{port: port}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go func() {
+				for channel := range channels {
+					channel.Reject(ssh.Prohibited, "no forwarding in the test")
+				}
+			}()
+			bastion.mu.Lock()
+			bastion.conns = append(bastion.conns, serverConn)
+			bastion.mu.Unlock()
+		}
+	}()
+	return bastion
+}
+
+// connections returns the number of SSH connections accepted so far.
+func (b *fakeBastion) connections() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.conns)
+}
+
+// drop closes every accepted connection, as a bastion restart would.
+func (b *fakeBastion) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+}
+
+func TestChainKeyDefaultsThePort(t *testing.T) {
+	flagHops, err := ParseJumpHosts("admin@bastion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlHops := []JumpHost{{Host: "bastion", Credentials: &CredentialRef{Username: "admin"}}}
+	if chainKey(flagHops) != chainKey(yamlHops) {
+		t.Errorf("-jump key %q differs from the jump_hosts key %q", chainKey(flagHops), chainKey(yamlHops))
+	}
+	if other, _ := ParseJumpHosts("admin@bastion:2222"); chainKey(other) == chainKey(yamlHops) {
+		t.Error("hops on different ports have the same key")
+	}
+}
+
+func TestBastionPoolSharesAndRedialsConnections(t *testing.T) {
+	bastion := newFakeBastion(t)
+	cfg := &Config{Username: "admin", Password: "secret", DialTimeout: 5 * time.Second, HostKeyCallback: ssh.InsecureIgnoreHostKey()}
+	pool := NewBastionPool()
+	defer pool.Close()
+
+	// Concurrent workers share one connection
+	hops := []JumpHost{{Host: "127.0.0.1", Port: bastion.port}}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pool.Dialer(context.Background(), hops, cfg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := bastion.connections(); n != 1 {
+		t.Fatalf("%d connections to the bastion, want 1", n)
+	}
+
+	// A dropped connection is replaced on next use
+	bastion.drop()
+	deadline := time.Now().Add(5 * time.Second)
+	for bastion.connections() < 2 && time.Now().Before(deadline) {
+		if _, err := pool.Dialer(context.Background(), hops, cfg); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := bastion.connections(); n != 2 {
+		t.Errorf("%d connections to the bastion after the drop, want 2", n)
+	}
+}
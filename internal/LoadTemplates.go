@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ntcPlatforms maps the platforms of the inventory to the platform names used by ntc-templates, tried in order.
+var ntcPlatforms = map[string][]string{
+	"ios":   {"cisco_ios"},
+	"iosxe": {"cisco_xe", "cisco_ios"},
+	"nxos":  {"cisco_nxos"},
+	"iosxr": {"cisco_xr"},
+}
+
+// templateEntry is one line of a template index: the template to use for the commands matching a pattern on a platform.
+type templateEntry struct {
+	platform string
+	command  *regexp.Regexp
+	parser   Parser
+}
+
+// templateIndex holds the templates loaded from disk, in index order; the first matching entry wins.
+type templateIndex struct {
+	entries []templateEntry
+}
+
+// loadedTemplates holds the templates loaded with LoadTemplates, nil until then.
+var loadedTemplates *templateIndex
+
+// lookup returns the parser of the first entry matching the command on the platform or one of its ntc-templates names.
+func (idx *templateIndex) lookup(platform, command string) (Parser, bool) {
+	if idx == nil {
+		return nil, false
+	}
+	for _, name := range append([]string{platform}, ntcPlatforms[platform]...) {
+		for _, entry := range idx.entries {
+			if entry.platform == name && entry.command.MatchString(command) {
+				return entry.parser, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// matchesCommand reports whether an entry of any platform matches the command.
+func (idx *templateIndex) matchesCommand(command string) bool {
+	if idx == nil {
+		return false
+	}
+	for _, entry := range idx.entries {
+		if entry.command.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandCompletion matches the optional completions of an index command, e.g. "sh[[ow]]".
+var commandCompletion = regexp.MustCompile(`\[\[([^\]]+)\]\]`)
+
+/*
+Expand the command column of a template index into a regex: "sh[[ow]] int[[erfaces]]" accepts "sh int", "show int",
+"show interfaces" and anything in between, as in ntc-templates.
+*/
+func commandPattern(command string) (*regexp.Regexp, error) {
+	pattern := commandCompletion.ReplaceAllStringFunc(command, func(match string) string {
+		completion := match[2 : len(match)-2]
+		var nested string
+		for i := len(completion) - 1; i >= 0; i-- {
+			nested = "(" + regexp.QuoteMeta(completion[i:i+1]) + nested + ")?"
+		}
+		return nested
+	})
+	return regexp.Compile("^(?:" + pattern + ")")
+}
+
+/*
+Load the TextFSM templates of a directory laid out like ntc-templates: an "index" file listing, after a
+"Template, Hostname, Platform, Command" header, which template parses which command on which platform.
+
+Parameters:
+  - dir string: The template directory holding the index and the template files.
+
+Returns:
+  - int: The number of index entries loaded.
+  - error: Returned if the index cannot be read or is malformed.
+
+Description:
+  - The loaded templates take precedence over the built-in parsers (see LookupParser). Inventory platforms are matched
+    to the ntc-templates names (ios to cisco_ios, nxos to cisco_nxos, ...) as well as to their own name.
+  - The Hostname column is not used. Entries combining several templates ("a.textfsm:b.textfsm") and templates using
+    regex features Go does not support are skipped and logged, so a full ntc-templates checkout can be used as is.
+*/
+
+func LoadTemplates(dir string) (int, error) {
+	file, err := os.Open(filepath.Join(dir, "index"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open template index: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	index := &templateIndex{}
+	compiled := make(map[string]Parser) // Templates shared by several entries are compiled once
+	header := true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read template index: %v", err)
+		}
+		if header {
+			header = false
+			if len(record) < 4 || record[0] != "Template" {
+				return 0, fmt.Errorf("template index must start with a \"Template, Hostname, Platform, Command\" header")
+			}
+			continue
+		}
+		if len(record) < 4 {
+			line, _ := reader.FieldPos(0)
+			return 0, fmt.Errorf("template index line %d: expected 4 columns, got %d", line, len(record))
+		}
+
+		name, platform, command := strings.TrimSpace(record[0]), strings.TrimSpace(record[2]), strings.TrimSpace(record[3])
+		if strings.Contains(name, ":") {
+			log.Printf("Skipping template %s: combining several templates is not supported", name)
+			continue
+		}
+		parser, ok := compiled[name]
+		if !ok {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				log.Printf("Skipping template %s: %v", name, err)
+				continue
+			}
+			fsm, err := ParseTextFSM(name, string(data))
+			if err != nil {
+				log.Printf("Skipping template %s: %v", name, err)
+				continue
+			}
+			parser = TemplateParser{Template: fsm}
+			compiled[name] = parser
+		}
+		pattern, err := commandPattern(command)
+		if err != nil {
+			log.Printf("Skipping template %s: invalid command %q: %v", name, command, err)
+			continue
+		}
+		index.entries = append(index.entries, templateEntry{platform: strings.ToLower(platform), command: pattern, parser: parser})
+	}
+
+	loadedTemplates = index
+	log.Printf("Loaded %d template index entries (%d templates) from %s", len(index.entries), len(compiled), dir)
+	return len(index.entries), nil
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"strings"
+)
+
+// CommandRows holds the interface data parsed from the output of one command.
+type CommandRows struct {
+	Command string
+	Rows    []InterfaceData
+}
+
+// isDescriptionCommand reports whether a command prints the full interface description, which 'show interface status'
+// truncates, so its Description replaces the one parsed from other commands. Abbreviations such as "sh int desc" count.
+func isDescriptionCommand(command string) bool {
+	fields := strings.Fields(strings.ToLower(command))
+	return len(fields) > 0 && strings.HasPrefix(fields[len(fields)-1], "desc")
+}
+
+/*
+Merge the interface data parsed from several commands run on the same device into one record per interface.
+
+Parameters:
+  - results []CommandRows: The parsed rows of each command, in the order the commands were run.
+
+Returns:
+  - []InterfaceData: One record per interface, in order of first appearance. Rows are joined on the normalised
+    interface name (see InterfaceName.Key), so "Gi1/0/1" and "GigabitEthernet1/0/1" are the same port. A field is taken from the first command
+    that provides a non-empty value for it, except the description, which is taken from a description command.
+*/
+
+func MergeInterfaceData(results []CommandRows) []InterfaceData {
+	var merged []InterfaceData
+	index := make(map[string]int) // Position of each interface in merged
+
+	for _, result := range results {
+		for _, row := range result.Rows {
+			key := row.Node + "|" + ParseInterfaceName(row.Interface).Key()
+			i, exists := index[key]
+			if !exists {
+				index[key] = len(merged)
+				merged = append(merged, row)
+				continue
+			}
+			if isDescriptionCommand(result.Command) && row.Description != "" {
+				merged[i].Description = row.Description
+			}
+			fillEmptyFields(&merged[i], row)
+		}
+	}
+	return merged
+}
+
+// fillEmptyFields copies the fields of src into the fields of dst that are still empty.
+func fillEmptyFields(dst *InterfaceData, src InterfaceData) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&dst.Slot, src.Slot)
+	fill(&dst.Port, src.Port)
+	fill(&dst.Description, src.Description)
+	fill(&dst.Status, src.Status)
+	fill(&dst.VLAN, src.VLAN)
+	fill(&dst.Duplex, src.Duplex)
+	fill(&dst.Speed, src.Speed)
+	fill(&dst.Type, src.Type)
+}
@@ -0,0 +1,41 @@
+package internal
+
+import "log"
+
+/*
+Rewrite the interface names of a sheet to their canonical form before comparison.
+
+Parameters:
+  - rows []InterfaceData: The rows read from a sheet or collected from the devices.
+
+Returns:
+  - []InterfaceData: A copy of the rows where every interface name uses the long form of the normalisation table
+    (Gi1/0/1, Ge1/0/1 and GigabitEthernet1/0/1 all become GigabitEthernet1/0/1, Te and TenGigE become
+    TenGigabitEthernet, Eth becomes Ethernet), and SLOT and PORT are derived again from the name, so sheets written
+    by different commands, IOS versions or port-audit releases compare equal.
+
+Names that cannot be parsed and rows without interface name (e.g. devices not collected) are kept as they are.
+*/
+
+func NormalizeInterfaceNames(rows []InterfaceData) []InterfaceData {
+	normalized := make([]InterfaceData, len(rows))
+	renamed := 0
+	for i, row := range rows {
+		normalized[i] = row
+		if row.Interface == "" {
+			continue
+		}
+		name := ParseInterfaceName(row.Interface)
+		if canonical := name.Long(); canonical != row.Interface {
+			normalized[i].Interface = canonical
+			renamed++
+		}
+		if port := name.PortColumn(); port != "" {
+			normalized[i].Slot, normalized[i].Port = name.SlotColumn(), port
+		}
+	}
+	if renamed > 0 {
+		log.Printf("Normalised %d interface names to their canonical form", renamed)
+	}
+	return normalized
+}
@@ -0,0 +1,25 @@
+package internal
+
+/*
+Parse the raw output of the commands run on a device and merge it into one record per interface.
+
+Parameters:
+  - outputs []CommandOutput: The raw output of each command, in the order the commands were run.
+  - device Device: The device the output was collected from.
+
+Returns:
+  - []InterfaceData: The merged interface data of the commands that completed; failed commands and commands without a
+    parser for the device's platform are skipped.
+*/
+
+func ParseOutputs(outputs []CommandOutput, device Device) []InterfaceData {
+	var results []CommandRows
+	for _, output := range outputs {
+		parser, ok := LookupParser(device.Platform, output.Command)
+		if output.Err != nil || !ok {
+			continue
+		}
+		results = append(results, CommandRows{Command: output.Command, Rows: ProcessOutput(output.Output, parser, device)})
+	}
+	return MergeInterfaceData(results)
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Parser turns the output of a command into interface data.
+type Parser interface {
+	Parse(output string, device Device) ([]InterfaceData, error)
+}
+
+// ParserKey identifies the parser of a command on a platform; an empty Platform applies to every platform.
+type ParserKey struct {
+	Platform string
+	Command  string
+}
+
+// parserRegistry holds the built-in parsers, see RegisterParser. Templates loaded from disk take precedence (see LoadTemplates).
+var parserRegistry = map[ParserKey]Parser{}
+
+/*
+Register the parser of a command on a platform.
+
+Parameters:
+  - platform string: The platform (Device.Platform) the parser applies to, or "" for every platform.
+  - command string: The CLI command whose output the parser reads.
+  - parser Parser: The parser.
+*/
+
+func RegisterParser(platform, command string, parser Parser) {
+	parserRegistry[ParserKey{Platform: strings.ToLower(platform), Command: command}] = parser
+}
+
+/*
+Look up the parser for the output of a command run on a device of the given platform.
+
+The templates loaded from disk are tried first, then the built-in parser registered for the platform and finally the
+built-in parser registered for every platform.
+
+Parameters:
+  - platform string: The platform of the device, matched case-insensitively; may be empty.
+  - command string: The command that was run.
+
+Returns:
+  - Parser: The parser to use.
+  - bool: False if no parser is known for the command on the platform.
+*/
+
+func LookupParser(platform, command string) (Parser, bool) {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	if parser, ok := loadedTemplates.lookup(platform, command); ok {
+		return parser, true
+	}
+	if parser, ok := parserRegistry[ParserKey{Platform: platform, Command: command}]; ok {
+		return parser, true
+	}
+	parser, ok := parserRegistry[ParserKey{Command: command}]
+	return parser, ok
+}
+
+// CommandSupported reports whether a parser is known for the command on at least one platform.
+func CommandSupported(command string) bool {
+	for key := range parserRegistry {
+		if key.Command == command {
+			return true
+		}
+	}
+	return loadedTemplates.matchesCommand(command)
+}
+
+// SupportedCommands returns the sorted list of commands with a built-in parser.
+func SupportedCommands() []string {
+	seen := make(map[string]bool)
+	for key := range parserRegistry {
+		seen[key.Command] = true
+	}
+	commands := make([]string, 0, len(seen))
+	for command := range seen {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// TemplateParser parses command output with a TextFSM template and maps its values to interface data.
+type TemplateParser struct {
+	Template *TextFSM
+}
+
+// templateFields lists, for each InterfaceData field, the template value names it is read from, covering the names
+// used by the built-in templates and by ntc-templates.
+var templateFields = map[string][]string{
+	"Interface":   {"INTERFACE", "PORT", "INTF"},
+	"Description": {"DESCRIPTION", "DESCRIP", "NAME"},
+	"Status":      {"STATUS", "LINK_STATUS"},
+	"Protocol":    {"PROTOCOL", "PROTOCOL_STATUS"},
+	"VLAN":        {"VLAN_ID", "VLAN"},
+	"Duplex":      {"DUPLEX"},
+	"Speed":       {"SPEED"},
+	"Type":        {"TYPE", "HARDWARE_TYPE"},
+}
+
+func (p TemplateParser) Parse(output string, device Device) ([]InterfaceData, error) {
+	records, err := p.Template.ParseText(output)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]InterfaceData, 0, len(records))
+	for _, record := range records {
+		field := func(name string) string {
+			for _, value := range templateFields[name] {
+				if v, ok := record[value]; ok {
+					return strings.TrimSpace(v)
+				}
+			}
+			return ""
+		}
+		if field("Interface") == "" {
+			continue // Not an interface record
+		}
+
+		// Combine the admin status and the line protocol, e.g. "up (up)", as the description parsers always did
+		status := field("Status")
+		if protocol := field("Protocol"); protocol != "" {
+			status += " (" + protocol + ")"
+		}
+		slot, port := ParseSlotAndPort(field("Interface"))
+		rows = append(rows, InterfaceData{
+			Node:        device.Host,
+			Interface:   field("Interface"),
+			Slot:        slot,
+			Port:        port,
+			Description: field("Description"),
+			Status:      status,
+			VLAN:        field("VLAN"),
+			Duplex:      field("Duplex"),
+			Speed:       field("Speed"),
+			Type:        field("Type"),
+		})
+	}
+	return rows, nil
+}
+
+// mustTemplateParser compiles a built-in template, panicking on error as regexp.MustCompile does.
+func mustTemplateParser(name, template string) Parser {
+	fsm, err := ParseTextFSM(name, template)
+	if err != nil {
+		panic(fmt.Sprintf("built-in template: %v", err))
+	}
+	return TemplateParser{Template: fsm}
+}
+
+// The built-in parsers read the same output on every platform; templates loaded from disk can override them per platform.
+func init() {
+	RegisterParser("", "show interface status", mustTemplateParser("show_interface_status", showIntStatusTemplate))
+	RegisterParser("", "show interface description", mustTemplateParser("show_interface_description", showIntDescriptionTemplate))
+	RegisterParser("", "show int description", mustTemplateParser("iosxr_show_int_description", showInterfaceDescriptionIOSXRTemplate))
+}
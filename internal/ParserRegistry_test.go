@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The regexes the built-in templates replaced, kept as the reference of the parity test.
+var (
+	removedShowIntStatus = regexp.MustCompile(
+		`^(?P<Interface>(GigabitEthernet|TenGigabitEthernet|Eth|Ge|Gi|Te|Fa)\d+(/\d+)+)\s+` +
+			`(?P<Description>.*?)\s+` +
+			`(?P<Status>up|down|administratively down|admin down|connected|notconnect|disabled|err-disabled|inactive|sfpAbsent|xcvrAbsent|monitoring|suspended)\s+` +
+			`(?P<VLAN>\d+|routed|trunk|unassigned)\s+` +
+			`(?P<Duplex>(a-)?full|(a-)?half|auto)\s+` +
+			`(?P<Speed>\S+)\s+` +
+			`(?P<Type>.*)$`)
+	removedShowIntDescription      = regexp.MustCompile(`^(?P<Interface>\S+)\s+(?P<Status>admin down|down|up)\s+(?P<Protocol>down|up)\s*(?P<Description>.*)$`)
+	removedShowIntDescriptionIOSXR = regexp.MustCompile(
+		`^(?P<Interface>(Te|GigabitEthernet|TenGigE|Eth|Ge|Ethernet)[\w/]+)\s+is\s+` +
+			`(?P<Status>up|down|administratively down|admin down|connected|disabled)\s+` +
+			`,\s+(?P<Protocol>up|down)\s+(?P<Description>\#\#.*?\#\#)\s*.*$`)
+)
+
+// parseWithRemovedRegex parses an output line by line as the removed parsers did; the templates trim the description
+// and the type, so the reference does too.
+func parseWithRemovedRegex(regex *regexp.Regexp, output string, device Device) []InterfaceData {
+	var rows []InterfaceData
+	for _, line := range strings.Split(output, "\n") {
+		matches := regex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		values := make(map[string]string)
+		for i, name := range regex.SubexpNames() {
+			if name != "" {
+				values[name] = matches[i]
+			}
+		}
+		status := values["Status"]
+		if values["Protocol"] != "" {
+			status += " (" + values["Protocol"] + ")"
+		}
+		slot, port := ParseSlotAndPort(values["Interface"])
+		rows = append(rows, InterfaceData{
+			Node: device.Host, Interface: values["Interface"], Slot: slot, Port: port,
+			Description: strings.TrimSpace(values["Description"]), Status: status,
+			VLAN: values["VLAN"], Duplex: values["Duplex"], Speed: values["Speed"], Type: strings.TrimSpace(values["Type"]),
+		})
+	}
+	return rows
+}
+
+const iosShowInterfaceStatus = `
+Port      Name               Status       Vlan       Duplex  Speed Type
+Gi1/0/1   Uplink to core     connected    trunk      a-full a-1000 10/100/1000BaseTX
+Gi1/0/2                      notconnect   10           auto   auto 10/100/1000BaseTX
+Gi1/0/3   Printer 2nd floor  disabled     20         a-half  a-100 10/100/1000BaseTX
+Gi1/0/24  Server rack 4 nic  notconnect   routed       auto   auto Not Present
+Te1/1/1                      err-disabled trunk        full    10G SFP-10GBase-SR
+Fa0       mgmt               connected    routed     a-full a-100 10/100BaseTX
+Po1       Uplink bundle      connected    trunk      a-full a-1000
+`
+
+const nxosShowInterfaceStatus = `
+--------------------------------------------------------------------------------
+Port          Name               Status    Vlan      Duplex  Speed   Type
+--------------------------------------------------------------------------------
+Eth1/1        uplink             connected trunk     full    10G     10Gbase-SR
+Eth1/2        --                 notconnec 1         auto    auto    10Gbase-SR
+Eth1/3        server 3           connected 100       full    10G     10Gbase-SR
+Eth1/1/2      breakout           connected 200       full    25G     QSFP-100G-SR4
+mgmt0         --                 connected routed    full    1000    --
+`
+
+const iosShowInterfaceDescription = `
+Interface                      Status         Protocol Description
+Gi1/0/1                        up             up       Uplink to core
+Gi1/0/2                        down           down
+Gi1/0/3                        admin down     down     Printer 2nd floor
+Vl1                            up             up       mgmt
+Po1                            up             up       Uplink bundle
+`
+
+const iosxrShowIntDescription = `
+Interface is up
+TenGigE0/0/0/1 is up , up ##Uplink to core## circuit 42
+GigabitEthernet0/0/0/2 is administratively down , down ##spare##
+Bundle-Ether1 is up , up ##bundle##
+`
+
+func TestBuiltInTemplatesMatchRemovedRegexes(t *testing.T) {
+	tests := []struct {
+		platform, command, output string
+		removed                   *regexp.Regexp
+		rows                      int
+	}{
+		{"ios", "show interface status", iosShowInterfaceStatus, removedShowIntStatus, 5},
+		{"nxos", "show interface status", nxosShowInterfaceStatus, removedShowIntStatus, 3}, // "notconnec" is not a known status,
+		{"ios", "show interface description", iosShowInterfaceDescription, removedShowIntDescription, 5},
+		{"iosxr", "show int description", iosxrShowIntDescription, removedShowIntDescriptionIOSXR, 2},
+	}
+	for _, test := range tests {
+		device := Device{Host: "sw1", Platform: test.platform}
+		parser, ok := LookupParser(test.platform, test.command)
+		if !ok {
+			t.Fatalf("no parser for %s %q", test.platform, test.command)
+		}
+		rows, err := parser.Parse(test.output, device)
+		if err != nil {
+			t.Fatalf("%s %q: %v", test.platform, test.command, err)
+		}
+		want := parseWithRemovedRegex(test.removed, test.output, device)
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("%s %q:\n got %+v\nwant %+v", test.platform, test.command, rows, want)
+		}
+		if len(rows) != test.rows {
+			t.Errorf("%s %q: %d rows, want %d", test.platform, test.command, len(rows), test.rows)
+		}
+	}
+}
+
+// ntcShowInterfaceStatus is an ntc-templates style template with a header rule, two line layouts and an Error action.
+const ntcShowInterfaceStatus = `Value PORT (\S+)
+Value NAME (.+?)
+Value STATUS (err-disabled|disabled|connected|notconnect|notconnec|inactive|up|down|monitoring|suspended|\S+)
+Value VLAN_ID (\S+)
+Value DUPLEX (\S+)
+Value SPEED (\S+)
+Value TYPE (.*)
+
+Start
+  ^-+\s*$$
+  ^Port\s+Name\s+Status\s+Vlan\s+Duplex\s+Speed\s+Type\s*$$
+  ^${PORT}\s+${NAME}\s+${STATUS}\s+${VLAN_ID}\s+${DUPLEX}\s+${SPEED}\s+${TYPE}\s*$$ -> Record
+  ^\s*$$
+  ^. -> Error
+`
+
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cisco_nxos_show_interface_status.textfsm":  ntcShowInterfaceStatus,
+		"arista_eos_show_interfaces_status.textfsm": ntcShowInterfaceStatus,
+		"lookaround.textfsm":                        "Value X ((?=a)a)\n\nStart\n  ^${X} -> Record\n",
+		"index": `
+# Comment lines and the header are skipped
+Template, Hostname, Platform, Command
+
+lookaround.textfsm, .*, cisco_nxos, sh[[ow]] ver[[sion]]
+cisco_nxos_show_run_a.textfsm:cisco_nxos_show_run_b.textfsm, .*, cisco_nxos, sh[[ow]] run[[ning-config]]
+cisco_nxos_show_interface_status.textfsm, .*, cisco_nxos, sh[[ow]] int[[erface]] st[[atus]]
+arista_eos_show_interfaces_status.textfsm, .*, arista_eos, sh[[ow]] int[[erfaces]] st[[atus]]
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := LoadTemplates(dir)
+	t.Cleanup(func() { loadedTemplates = nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 2 {
+		t.Errorf("loaded %d entries, want 2 (the combined and look-around entries are skipped)", loaded)
+	}
+
+	// nxos is matched to cisco_nxos, and the loaded template is used before the built-in one
+	parser, ok := LookupParser("nxos", "sh int status")
+	if !ok {
+		t.Fatal("no parser for nxos 'sh int status'")
+	}
+	if name := parser.(TemplateParser).Template.Name; name != "cisco_nxos_show_interface_status.textfsm" {
+		t.Errorf("nxos parser is %s, want the loaded template", name)
+	}
+	rows, err := parser.Parse(nxosShowInterfaceStatus, Device{Host: "n1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[0].Interface != "Eth1/1" || rows[0].VLAN != "trunk" || rows[1].Status != "notconnec" || rows[4].Interface != "mgmt0" {
+		t.Errorf("rows = %+v", rows)
+	}
+	if _, err := parser.Parse("unexpected line\n", Device{Host: "n1"}); err == nil {
+		t.Error("Error action did not fail the parse")
+	}
+
+	// Platforms without loaded template keep the built-in parsers, platforms unknown to both have none
+	parser, _ = LookupParser("ios", "show interface status")
+	if name := parser.(TemplateParser).Template.Name; name != "show_interface_status" {
+		t.Errorf("ios parser is %s, want the built-in template", name)
+	}
+	if _, ok := LookupParser("arista_eos", "show interfaces status"); !ok {
+		t.Error("no parser for arista_eos 'show interfaces status'")
+	}
+	if _, ok := LookupParser("junos", "show interfaces status"); ok {
+		t.Error("parser found for junos")
+	}
+	if _, ok := LookupParser("nxos", "show version"); ok {
+		t.Error("parser found for a template skipped at load")
+	}
+}
+
+// TestShowIntStatusTemplateWidenedCases pins each case the status template accepts beyond the original parser, which
+// only took two-level Gi/Ge/Eth ports, the statuses connected and disabled, numeric VLANs and plain duplex values.
+func TestShowIntStatusTemplateWidenedCases(t *testing.T) {
+	parser, ok := LookupParser("ios", "show interface status")
+	if !ok {
+		t.Fatal("no parser for ios 'show interface status'")
+	}
+	tests := []struct {
+		line string
+		want InterfaceData // Node and Slot/Port are not checked
+	}{
+		// Multi-level ports, and the Te and Fa types
+		{"Gi1/0/24  access             connected    10           full   1000 10/100/1000BaseTX",
+			InterfaceData{Interface: "Gi1/0/24", Description: "access", Status: "connected", VLAN: "10", Duplex: "full", Speed: "1000", Type: "10/100/1000BaseTX"}},
+		{"Te1/1/1   core               connected    trunk        full    10G SFP-10GBase-SR",
+			InterfaceData{Interface: "Te1/1/1", Description: "core", Status: "connected", VLAN: "trunk", Duplex: "full", Speed: "10G", Type: "SFP-10GBase-SR"}},
+		{"Fa0/1     printer            connected    20         a-full  a-100 10/100BaseTX",
+			InterfaceData{Interface: "Fa0/1", Description: "printer", Status: "connected", VLAN: "20", Duplex: "a-full", Speed: "a-100", Type: "10/100BaseTX"}},
+		// Statuses
+		{"Gi1/0/2                      notconnect   1            auto   auto 10/100/1000BaseTX",
+			InterfaceData{Interface: "Gi1/0/2", Status: "notconnect", VLAN: "1", Duplex: "auto", Speed: "auto", Type: "10/100/1000BaseTX"}},
+		{"Gi1/0/3                      err-disabled 1            auto   auto 10/100/1000BaseTX",
+			InterfaceData{Interface: "Gi1/0/3", Status: "err-disabled", VLAN: "1", Duplex: "auto", Speed: "auto", Type: "10/100/1000BaseTX"}},
+		{"Gi1/0/4                      inactive     999          auto   auto 10/100/1000BaseTX",
+			InterfaceData{Interface: "Gi1/0/4", Status: "inactive", VLAN: "999", Duplex: "auto", Speed: "auto", Type: "10/100/1000BaseTX"}},
+		{"Te1/1/2                      sfpAbsent    1            full    10G Not Present",
+			InterfaceData{Interface: "Te1/1/2", Status: "sfpAbsent", VLAN: "1", Duplex: "full", Speed: "10G", Type: "Not Present"}},
+		{"Eth1/5                       xcvrAbsent   1            auto   auto --",
+			InterfaceData{Interface: "Eth1/5", Status: "xcvrAbsent", VLAN: "1", Duplex: "auto", Speed: "auto", Type: "--"}},
+		{"Gi1/0/5   span dest          monitoring   1          a-full a-1000 10/100/1000BaseTX",
+			InterfaceData{Interface: "Gi1/0/5", Description: "span dest", Status: "monitoring", VLAN: "1", Duplex: "a-full", Speed: "a-1000", Type: "10/100/1000BaseTX"}},
+		{"Gi1/0/6   lacp member        suspended    trunk        full   1000 10/100/1000BaseTX",
+			InterfaceData{Interface: "Gi1/0/6", Description: "lacp member", Status: "suspended", VLAN: "trunk", Duplex: "full", Speed: "1000", Type: "10/100/1000BaseTX"}},
+		// VLAN modes and auto-negotiated half duplex
+		{"Gi1/0/7   l3 link            connected    routed     a-half  a-100 10/100/1000BaseTX",
+			InterfaceData{Interface: "Gi1/0/7", Description: "l3 link", Status: "connected", VLAN: "routed", Duplex: "a-half", Speed: "a-100", Type: "10/100/1000BaseTX"}},
+		{"Gi1/0/8                      disabled     unassigned   auto   auto 10/100/1000BaseTX",
+			InterfaceData{Interface: "Gi1/0/8", Status: "disabled", VLAN: "unassigned", Duplex: "auto", Speed: "auto", Type: "10/100/1000BaseTX"}},
+	}
+	for _, test := range tests {
+		rows, err := parser.Parse(test.line+"\n", Device{Host: "sw1", Platform: "ios"})
+		if err != nil {
+			t.Fatalf("%q: %v", test.line, err)
+		}
+		if len(rows) != 1 {
+			t.Errorf("%q: %d rows, want 1", test.line, len(rows))
+			continue
+		}
+		got := rows[0]
+		got.Node, got.Slot, got.Port = "", "", ""
+		if got != test.want {
+			t.Errorf("%q:\n got %+v\nwant %+v", test.line, got, test.want)
+		}
+	}
+
+	// Still rejected: truncated statuses, ports without slot, headers and VLANs out of the accepted set
+	for _, line := range []string{
+		"Eth1/2        --                 notconnec 1         auto    auto    10Gbase-SR",
+		"Po1       Uplink bundle      connected    trunk      a-full a-1000",
+		"Port      Name               Status       Vlan       Duplex  Speed Type",
+		"Gi1/0/9   dot1q              connected    dot1q      full   1000 10/100/1000BaseTX",
+	} {
+		if rows, _ := parser.Parse(line+"\n", Device{Host: "sw1", Platform: "ios"}); len(rows) != 0 {
+			t.Errorf("%q parsed as %+v, want no row", line, rows)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PlatformProfile describes how devices of a given platform are audited.
+type PlatformProfile struct {
+	Name         string   // Platform name as used in the inventory (e.g. ios, nxos)
+	Commands     []string // CLI commands executed on the device, in order, over one connection
+	ShellMode    bool     // Run commands in an interactive shell by default, see ShellExecutor
+	PagerCommand string   // Command disabling the pager in shell mode
+}
+
+// platformRegistry maps each supported platform (Device.Platform) to the commands used to audit it and how they are run.
+// IOS and IOS-XE exec channels often reject or truncate commands, so these platforms use shell mode by default.
+var platformRegistry = map[string]PlatformProfile{
+	"ios":   {Name: "ios", Commands: []string{"show interface status"}, ShellMode: true, PagerCommand: "terminal length 0"},
+	"iosxe": {Name: "iosxe", Commands: []string{"show interface status"}, ShellMode: true, PagerCommand: "terminal length 0"},
+	"nxos":  {Name: "nxos", Commands: []string{"show interface status"}, PagerCommand: "terminal length 0"},
+	"iosxr": {Name: "iosxr", Commands: []string{"show int description"}, PagerCommand: "terminal length 0"},
+}
+
+// AutoProfile selects the commands registered for each device's platform.
+const AutoProfile = "auto"
+
+// collectionProfiles maps each named collection profile (-profile) to the commands used per platform.
+var collectionProfiles = map[string]map[string][]string{
+	"status": {
+		"ios":   {"show interface status"},
+		"iosxe": {"show interface status"},
+		"nxos":  {"show interface status"},
+	},
+	"description": {
+		"ios":   {"show interface description"},
+		"iosxe": {"show interface description"},
+		"iosxr": {"show int description"},
+	},
+	// full joins the status output (VLAN, duplex, speed, type) with the description output (full description)
+	// into one row per port. NX-OS and IOS-XR only have one parsed command each.
+	"full": {
+		"ios":   {"show interface status", "show interface description"},
+		"iosxe": {"show interface status", "show interface description"},
+		"nxos":  {"show interface status"},
+		"iosxr": {"show int description"},
+	},
+}
+
+/*
+Look up the audit profile for a platform name taken from the inventory.
+
+Parameters:
+  - platform string: The platform of the device (Device.Platform), matched case-insensitively.
+
+Returns:
+  - PlatformProfile: The commands and execution settings to use for the platform.
+  - error: Returned if the platform is not supported.
+*/
+
+func LookupPlatform(platform string) (PlatformProfile, error) {
+	name := strings.ToLower(strings.TrimSpace(platform))
+	profile, ok := platformRegistry[name]
+	if !ok {
+		return PlatformProfile{}, fmt.Errorf("unsupported platform %q (supported: %s)", platform, strings.Join(SupportedPlatforms(), ", "))
+	}
+	return profile, nil
+}
+
+// SupportedPlatforms returns the sorted list of platform names known to the registry.
+func SupportedPlatforms() []string {
+	return strings.Split(joinSorted(platformRegistry), ", ")
+}
+
+/*
+Resolve the audit profile for a device from the command selection of the run.
+
+Parameters:
+  - platform string: The platform of the device (Device.Platform).
+  - profile string: The collection profile name (auto, status, description).
+  - command string: Explicit commands to run on every device, separated by commas; take precedence over the profile when set.
+
+Returns:
+  - PlatformProfile: The commands and execution settings to use for the device.
+  - error: Returned if the platform is unknown or the selection has no supported command for it.
+
+A platform missing from the registry can still be audited with explicit commands parsed by templates loaded with
+-templates; its commands are then run with exec requests.
+*/
+
+func ResolveProfile(platform, profile, command string) (PlatformProfile, error) {
+	resolved, err := LookupPlatform(platform)
+	if err != nil {
+		if command == "" || loadedTemplates == nil {
+			return resolved, err
+		}
+		resolved = PlatformProfile{Name: strings.ToLower(strings.TrimSpace(platform)), PagerCommand: "terminal length 0"}
+	}
+
+	switch {
+	case command != "":
+		resolved.Commands = SplitCommands(command)
+	case profile == "" || profile == AutoProfile:
+		return resolved, nil
+	default:
+		commands, ok := collectionProfiles[profile]
+		if !ok {
+			return resolved, fmt.Errorf("unknown profile %q", profile)
+		}
+		if resolved.Commands, ok = commands[resolved.Name]; !ok {
+			return resolved, fmt.Errorf("profile %q is not supported on platform %q", profile, resolved.Name)
+		}
+	}
+
+	for _, command := range resolved.Commands {
+		if _, ok := LookupParser(resolved.Name, command); !ok {
+			return resolved, fmt.Errorf("unsupported command %q on platform %q", command, resolved.Name)
+		}
+	}
+	return resolved, nil
+}
+
+// SplitCommands splits a comma-separated list of commands, dropping empty entries.
+func SplitCommands(list string) []string {
+	var commands []string
+	for _, command := range strings.Split(list, ",") {
+		if command = strings.TrimSpace(command); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// joinSorted returns the keys of a map as a sorted, comma-separated list.
+func joinSorted[V any](m map[string]V) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
@@ -20,7 +20,7 @@ func PrintUsageGuide(guide string) bool {
 	box := paddedBox.WithTitle(title).WithTitleTopLeft().Sprint(pterm.NewStyle(pterm.FgLightWhite, pterm.Italic).Sprint(guide))
 
 	pterm.DefaultPanel.WithPanels([][]pterm.Panel{
-		{{box}},
+		{{Data: box}},
 	}).Render()
 
 	return true
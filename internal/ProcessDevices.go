@@ -1,37 +1,107 @@
 package internal
 
 import (
+	"context"
 	"log"
-	"sync"
+	"time"
 )
 
 /*
 Manage the processing of a single network device within a goroutine.
 
 Parameters:
+  - ctx context.Context: The run context; once cancelled, no new attempt is started and the open session is closed.
   - device Device: A struct containing details about the device.
-  - dataChan chan<- InterfaceData: A channel used to send processed interface data back to the main program.
-                                   The channel is "send-only" within this function.
-  - username string: The username required for SSH authentication.
-  - password string: The password required for SSH authentication.
+  - cfg *Config: The run configuration holding the credentials, the command selection and the retry policy.
+
+Returns:
+  - DeviceResult: The parsed rows, raw output, timings and outcome of the device, with the failure class of the last
+    attempt if it failed.
 
 Description:
   - This function logs the beginning of the processing for a specific device.
-  - It invokes 'ConnectAndExecute' to establish an SSH connection, execute a command relevant to the device's platform,
-    and handle the output. Any occurring errors during connection or execution are logged.
-  - After processing, it logs the completion of the operation for the device.
+  - It invokes 'ConnectAndExecute' to establish a connection, execute the commands registered for the device's
+    platform (see PlatformRegistry.go), then 'ParseOutputs' to parse their output.
+  - Failures that may be transient (DNS, refused connections, timeouts) are retried up to cfg.Retries times with an
+    exponential backoff starting at cfg.RetryBackoff. Authentication and host key failures are never retried.
+  - Each attempt waits for a session slot of the device's group and for the connection rate limit (cfg.Limiter).
+  - When the run is cancelled (signal or -run-timeout) the rows already collected are kept and the device is reported
+    as partial, or as not collected if it has none.
+  - Only the rows and output of the last attempt are returned, so a retry never duplicates rows.
   - This function does not manage concurrency directly (e.g., it does not call 'wg.Done()'); instead, it is designed to
     be managed by a worker pool where concurrency control is handled externally.
 
 Usage:
-  - Intended to be run inside a worker goroutine as part of a pool that processes multiple devices concurrently.
-  - The worker pool is responsible for managing the lifecycle of goroutines, including the synchronization of their completion.
+  - Intended to be run inside a worker goroutine as part of a pool that processes multiple devices concurrently
+    (see CollectResults).
+  - The worker pool is responsible for managing the lifecycle of goroutines, including the synchronization of their completion,
+    and for aggregating the results once every worker has returned.
 */
 
-func ProcessDevice(device Device, dataChan chan<- InterfaceData, username, password string, selectedCommand string, successCounter *int, failureCounter *int, mu *sync.Mutex) {
-	log.Printf("Starting processing for device: %s", device.Host)
-	if err := ConnectAndExecute(device, username, password, dataChan, selectedCommand, successCounter, failureCounter, mu); err != nil {
-		log.Printf("Failed to connect or execute on device %s: %v", device.Host, err)
+func ProcessDevice(ctx context.Context, device Device, cfg *Config) DeviceResult {
+	log.Printf("Starting processing for device: %s (platform: %s)", device.Host, device.Platform)
+	start := time.Now()
+	status := DeviceStatus{Host: device.Host, Platform: device.Platform, Transport: DeviceTransport(device)}
+
+	var outputs []CommandOutput
+	var rows []InterfaceData
+	var err error
+	backoff := cfg.RetryBackoff
+	for {
+		// Per-group session cap, not held while backing off
+		release, waitErr := cfg.Limiter.Acquire(ctx, device.Group)
+		if waitErr != nil {
+			err = interrupted(ctx, waitErr)
+			status.Class = ClassifyError(err)
+			break
+		}
+		status.Attempts++
+		outputs, err = ConnectAndExecute(ctx, device, cfg)
+		release()
+		rows = ParseOutputs(outputs, device)
+		err = interrupted(ctx, err) // A failure caused by the cancellation is not retried
+		status.Class = ClassifyError(err)
+		if err == nil || !status.Class.Retryable() || status.Attempts > cfg.Retries {
+			break
+		}
+		log.Printf("Attempt %d for device %s failed (%s): %v. Retrying in %s", status.Attempts, device.Host, status.Class, err, backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			err = interrupted(ctx, err)
+			status.Class = FailureCancelled
+			break
+		}
+		backoff *= 2
+	}
+
+	// A device answering every command without a single parsed interface is reported as a parse failure
+	if err == nil && len(rows) == 0 {
+		err = errNoRows
+		status.Class = FailureNoRows
 	}
-	log.Printf("Completed processing for device: %s", device.Host)
+
+	switch {
+	case err == nil:
+		status.Status = StatusOK
+	case len(rows) > 0:
+		status.Status = StatusPartial
+	case status.Class == FailureCancelled:
+		status.Status = StatusNotCollected
+	default:
+		status.Status = StatusFailed
+	}
+	if err != nil {
+		status.Error = err.Error()
+		log.Printf("Failed to connect or execute on device %s (%s): %v", device.Host, status.Class, err)
+	}
+
+	status.Interfaces = len(rows)
+	status.Duration = time.Since(start)
+	log.Printf("Completed processing for device: %s (%s, %d interfaces, %d attempts)", device.Host, status.Status, status.Interfaces, status.Attempts)
+	return DeviceResult{Device: device, Status: status, Rows: rows, Outputs: outputs, Started: start, Err: err}
 }
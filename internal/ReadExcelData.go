@@ -18,11 +18,7 @@ Returns:
 
 func ReadExcelData(sheet *xlsx.Sheet) ([]InterfaceData, error) {
 	var data []InterfaceData
-	var headerMap = make(map[string]int)
-
-	for i, cell := range sheet.Rows[0].Cells {
-		headerMap[cell.String()] = i
-	}
+	headerMap := SheetHeaders(sheet)
 
 	// Required headers
 	requiredHeaders := []string{"Switch Name", "SLOT", "PORT", "Port Status", "Port Description"} //"Interface", "TYPE", "VLAN", "Duplex", "SPEED" not required at the minute
@@ -37,21 +33,42 @@ func ReadExcelData(sheet *xlsx.Sheet) ([]InterfaceData, error) {
 	for _, row := range sheet.Rows[1:] { // Skip the header row
 		entry := InterfaceData{
 			Node:        getCellValue(row, headerMap["Switch Name"]),
-			Interface:   getCellValue(row, headerMap["Interface"]),
+			Interface:   getColumnValue(row, headerMap, "Interface"), // Optional: rows are matched on SLOT and PORT without it
 			Slot:        getCellValue(row, headerMap["SLOT"]),
 			Port:        getCellValue(row, headerMap["PORT"]),
 			Description: getCellValue(row, headerMap["Port Description"]),
 			Status:      getCellValue(row, headerMap["Port Status"]),
-			Speed:       getCellValue(row, headerMap["SPEED"]),
-			Duplex:      getCellValue(row, headerMap["Duplex"]),
-			VLAN:        getCellValue(row, headerMap["VLAN"]),
-			Type:        getCellValue(row, headerMap["TYPE"]),
+			Speed:       getColumnValue(row, headerMap, "SPEED"), // Optional columns, empty when the sheet predates them
+			Duplex:      getColumnValue(row, headerMap, "Duplex"),
+			VLAN:        getColumnValue(row, headerMap, "VLAN"),
+			Type:        getColumnValue(row, headerMap, "TYPE"),
 		}
 		data = append(data, entry)
 	}
 	return data, nil
 }
 
+// SheetHeaders maps the headers of the first row of a sheet to their column index.
+func SheetHeaders(sheet *xlsx.Sheet) map[string]int {
+	headerMap := make(map[string]int)
+	if len(sheet.Rows) == 0 {
+		return headerMap
+	}
+	for i, cell := range sheet.Rows[0].Cells {
+		headerMap[cell.String()] = i
+	}
+	return headerMap
+}
+
+// Get the value of the cell of an optional column, empty when the sheet has no such column.
+func getColumnValue(row *xlsx.Row, headerMap map[string]int, header string) string {
+	index, ok := headerMap[header]
+	if !ok {
+		return ""
+	}
+	return getCellValue(row, index)
+}
+
 // Get the value of a cell by index with a fallback for missing cells.
 func getCellValue(row *xlsx.Row, index int) string {
 	if index < len(row.Cells) {
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/tealeg/xlsx"
+)
+
+// newTestSheet returns a sheet holding the header row and the data rows.
+func newTestSheet(t *testing.T, headers []string, rows ...[]string) *xlsx.Sheet {
+	t.Helper()
+	file := xlsx.NewFile()
+	addTestSheet(t, file, "Baseline", headers, rows...)
+	return file.Sheet["Baseline"]
+}
+
+func TestReadExcelDataWithoutInterfaceColumnLeavesInterfaceEmpty(t *testing.T) {
+	sheet := newTestSheet(t, []string{"Switch Name", "SLOT", "PORT", "Port Status", "Port Description"},
+		[]string{"sw1", "1/0", "1", "connected", "uplink"},
+		[]string{"sw1", "1/0", "2", "notconnect", "spare"},
+	)
+	data, err := ReadExcelData(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = NormalizeInterfaceNames(data)
+	if len(data) != 2 {
+		t.Fatalf("read %d rows, want 2", len(data))
+	}
+	for _, d := range data {
+		if d.Interface != "" {
+			t.Errorf("Interface = %q, want empty for a sheet without Interface column", d.Interface)
+		}
+	}
+}
+
+func TestReadExcelDataLeavesMissingOptionalColumnsEmpty(t *testing.T) {
+	sheet := newTestSheet(t, []string{"Switch Name", "Interface", "SLOT", "PORT", "Port Status", "Port Description"},
+		[]string{"sw1", "GigabitEthernet1/0/1", "1/0", "1", "connected", "uplink"},
+	)
+	data, err := ReadExcelData(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := data[0]; d.VLAN != "" || d.Speed != "" || d.Duplex != "" || d.Type != "" {
+		t.Errorf("optional fields = %q, %q, %q, %q, want empty", d.VLAN, d.Speed, d.Duplex, d.Type)
+	}
+}
@@ -14,10 +14,24 @@ type Device struct {
 	Port      string `yaml:"port"`
 	Platform  string `yaml:"platform"`
 	Transport string `yaml:"transport"`
+	KeyFile   string `yaml:"key_file,omitempty"` // Private key used for this device instead of the global -key
+	Agent     bool   `yaml:"agent,omitempty"`    // Authenticate this device with the SSH agent
+	Group     string `yaml:"group,omitempty"`    // Name of the inventory group the device belongs to
+
+	Credentials *CredentialRef `yaml:"credentials,omitempty"` // Device credentials, fall back to the group and then the command line
+	JumpHosts   []JumpHost     `yaml:"jump_hosts,omitempty"`  // Bastions traversed to reach the device, fall back to the group and then the global ones
+}
+
+// Group holds settings shared by the devices that reference it.
+type Group struct {
+	Credentials *CredentialRef `yaml:"credentials,omitempty"`
+	JumpHosts   []JumpHost     `yaml:"jump_hosts,omitempty"`
+	MaxSessions int            `yaml:"max_sessions,omitempty"` // Concurrent sessions allowed to the group's devices, 0 uses -group-sessions
 }
 
 type Inventory struct {
-	Devices []Device `yaml:"devices"`
+	Groups  map[string]Group `yaml:"groups,omitempty"`
+	Devices []Device         `yaml:"devices"`
 }
 
 /*
@@ -44,7 +58,48 @@ func ReadInventory(filename string, logger *pterm.Logger) (*Inventory, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal inventory data: %v", err)
 	}
+	// Apply the group settings to each member device
+	for i := range inventory.Devices {
+		device := &inventory.Devices[i]
+		if device.Group == "" {
+			continue
+		}
+		group, ok := inventory.Groups[device.Group]
+		if !ok {
+			return nil, fmt.Errorf("device %s references unknown group %q", device.Host, device.Group)
+		}
+		device.Credentials = mergeCredentials(device.Credentials, group.Credentials)
+		if len(device.JumpHosts) == 0 {
+			device.JumpHosts = group.JumpHosts
+		}
+	}
+
 	log.Printf("Successfully loaded inventory: %d devices ready for processing.", len(inventory.Devices))               // log to the file
 	logger.Trace("Inventory loaded: ready for device processing.", logger.Args("Device count", len(inventory.Devices))) // log to the screen
 	return &inventory, nil
 }
+
+// mergeCredentials fills the fields missing from the device credentials with those of its group.
+func mergeCredentials(device, group *CredentialRef) *CredentialRef {
+	if group == nil {
+		return device
+	}
+	if device == nil {
+		merged := *group
+		return &merged
+	}
+
+	merged := *device
+	if merged.Username == "" {
+		merged.Username = group.Username
+	}
+	if merged.PasswordEnv == "" && merged.PasswordFile == "" && merged.PasswordKeyring == "" {
+		merged.PasswordEnv = group.PasswordEnv
+		merged.PasswordFile = group.PasswordFile
+		merged.PasswordKeyring = group.PasswordKeyring
+	}
+	if merged.KeyFile == "" {
+		merged.KeyFile = group.KeyFile
+	}
+	return &merged
+}
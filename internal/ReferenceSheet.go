@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/tealeg/xlsx"
+	"strings"
+	"time"
+)
+
+// Comparison references (-reference); a sheet or a date is given as "sheet:<name>" or "date:<YYYY-MM-DD>".
+const (
+	ReferenceBaseline = "baseline" // The sheet named Baseline
+	ReferencePrevious = "previous" // The Audit sheet of the most recent run started before the new one
+	referenceSheet    = "sheet:"
+	referenceDate     = "date:"
+)
+
+// ValidateReference checks the value of -reference.
+func ValidateReference(reference string) error {
+	switch {
+	case reference == ReferenceBaseline, reference == ReferencePrevious:
+	case strings.HasPrefix(reference, referenceSheet) && len(reference) > len(referenceSheet):
+	case strings.HasPrefix(reference, referenceDate):
+		if _, err := time.ParseInLocation("2006-01-02", strings.TrimPrefix(reference, referenceDate), time.Local); err != nil {
+			return fmt.Errorf("invalid reference date %q, expected date:YYYY-MM-DD", reference)
+		}
+	default:
+		return fmt.Errorf("unknown reference %q (supported: %s, %s, sheet:<name>, date:<YYYY-MM-DD>)", reference, ReferenceBaseline, ReferencePrevious)
+	}
+	return nil
+}
+
+// auditSheetDate returns the time of an Audit sheet from its name, "Audit <run ID>" (see AuditSheetName and runIDTime).
+func auditSheetDate(name string) (time.Time, bool) {
+	runID, found := strings.CutPrefix(name, "Audit ")
+	if !found {
+		return time.Time{}, false
+	}
+	return runIDTime(runID)
+}
+
+/*
+Select the sheet the new audit is compared against.
+
+Parameters:
+  - file *xlsx.File: The workbook holding the reference, the audit workbook or a separate one (-reference-file).
+  - reference string: The reference (see ValidateReference): baseline, previous, sheet:<name> or date:<YYYY-MM-DD>.
+  - newSheetName string: The sheet of the new audit, never selected as its own reference; empty for a separate workbook.
+  - runTime time.Time: The start time of the new audit: previous and date: only select the sheets of earlier runs; zero for no limit.
+
+Returns:
+  - *xlsx.Sheet: The reference sheet.
+  - error: Returned if no sheet of the workbook matches the reference.
+*/
+
+func SelectReferenceSheet(file *xlsx.File, reference, newSheetName string, runTime time.Time) (*xlsx.Sheet, error) {
+	switch {
+	case reference == ReferenceBaseline || reference == "":
+		if sheet := file.Sheet["Baseline"]; sheet != nil {
+			return sheet, nil
+		}
+		return nil, fmt.Errorf("no Baseline sheet in the reference workbook")
+	case strings.HasPrefix(reference, referenceSheet):
+		name := strings.TrimPrefix(reference, referenceSheet)
+		if sheet := file.Sheet[name]; sheet != nil && name != newSheetName {
+			return sheet, nil
+		}
+		return nil, fmt.Errorf("no sheet named %q in the reference workbook", name)
+	}
+
+	// The latest Audit sheet of a run started before this one, of the given day for a date; sheets of the same time are
+	// taken in workbook order
+	var day time.Time
+	if date, found := strings.CutPrefix(reference, referenceDate); found {
+		var err error
+		if day, err = time.ParseInLocation("2006-01-02", date, time.Local); err != nil {
+			return nil, fmt.Errorf("invalid reference date %q, expected date:YYYY-MM-DD", reference)
+		}
+	}
+	var selected *xlsx.Sheet
+	var selectedDate time.Time
+	for _, sheet := range file.Sheets {
+		date, ok := auditSheetDate(sheet.Name)
+		if !ok || sheet.Name == newSheetName || (!runTime.IsZero() && !date.Before(runTime)) || (!day.IsZero() && date.Format("2006-01-02") != day.Format("2006-01-02")) {
+			continue
+		}
+		if selected == nil || !date.Before(selectedDate) {
+			selected, selectedDate = sheet, date
+		}
+	}
+	if selected == nil {
+		if !day.IsZero() {
+			return nil, fmt.Errorf("no Audit sheet of %s in the reference workbook", day.Format("2006-01-02"))
+		}
+		return nil, fmt.Errorf("no previous Audit sheet in the reference workbook")
+	}
+	return selected, nil
+}
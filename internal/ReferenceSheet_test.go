@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/tealeg/xlsx"
+)
+
+func TestSelectReferenceSheetTakesOnlyEarlierRuns(t *testing.T) {
+	current := "18102026-090000.000-bbbb"
+	runTime, _ := runIDTime(current)
+	sheets := []string{
+		"Baseline",
+		AuditSheetName("18102026-100000.000-aaaa"), // A run started after this one, e.g. by a script
+		AuditSheetName(current),
+		AuditSheetName("18102026-080000.000-cccc"),
+		"Audit 18102026-070000",
+		"Audit 17102026",
+	}
+	tests := []struct{ reference, want string }{
+		{ReferencePrevious, "Audit 18102026-080000.000-cccc"},
+		{"date:2026-10-18", "Audit 18102026-080000.000-cccc"},
+		{"date:2026-10-17", "Audit 17102026"},
+	}
+	// The workbook order does not matter, only the run times
+	for _, order := range [][]int{{0, 1, 2, 3, 4, 5}, {5, 4, 3, 2, 1, 0}, {0, 3, 2, 5, 4, 1}} {
+		file := xlsx.NewFile()
+		for _, i := range order {
+			addTestSheet(t, file, sheets[i], []string{"Switch Name"})
+		}
+		for _, test := range tests {
+			sheet, err := SelectReferenceSheet(file, test.reference, AuditSheetName(current), runTime)
+			if err != nil {
+				t.Errorf("order %v, %s: %v", order, test.reference, err)
+			} else if sheet.Name != test.want {
+				t.Errorf("order %v, %s: selected %q, want %q", order, test.reference, sheet.Name, test.want)
+			}
+		}
+	}
+
+	// No run before the first one
+	file := xlsx.NewFile()
+	addTestSheet(t, file, AuditSheetName(current), []string{"Switch Name"})
+	addTestSheet(t, file, AuditSheetName("18102026-100000.000-aaaa"), []string{"Switch Name"})
+	if sheet, err := SelectReferenceSheet(file, ReferencePrevious, AuditSheetName(current), runTime); err == nil {
+		t.Errorf("selected %q as previous of the first run", sheet.Name)
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Parse a directory of saved command output, laid out as "<host>__<command>.txt" like the raw output of a run
+(see WriteRawOutput), into one result per host without connecting to any device.
+
+Parameters:
+  - dir string: The directory holding the saved output, e.g. an extracted report archive's raw_output directory.
+  - inventory *Inventory: Provides the platform of each host; hosts missing from it are replayed without a platform.
+
+Returns:
+  - []DeviceResult: One result per host, sorted by host, ready for the Excel operations and the comparison.
+  - error: Returned if the directory cannot be read or holds no output of a supported command.
+*/
+
+func ReplayRawOutput(dir string, inventory *Inventory) ([]DeviceResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replay directory: %v", err)
+	}
+
+	// File names hold the host with unsafe characters replaced, so match the inventory the same way
+	devices := make(map[string]Device)
+	for _, device := range inventory.Devices {
+		devices[strings.TrimSuffix(RawOutputFileName(device.Host, ""), "__.txt")] = device
+	}
+
+	byHost := make(map[string][]CommandOutput)
+	for _, entry := range entries {
+		host, suffix, found := strings.Cut(entry.Name(), "__")
+		if entry.IsDir() || !found || !strings.HasSuffix(suffix, ".txt") {
+			continue // Not saved output, e.g. an unparsed report
+		}
+		// The spaces of the command were replaced by underscores, see RawOutputFileName
+		command := strings.ReplaceAll(strings.TrimSuffix(suffix, ".txt"), "_", " ")
+		if _, ok := LookupParser(devices[host].Platform, command); !ok {
+			log.Printf("Skipping %s: no parser for the command in its name", entry.Name())
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read saved output: %v", err)
+		}
+		byHost[host] = append(byHost[host], CommandOutput{Command: command, Output: string(data), Err: checkCommandOutput(command, string(data))})
+	}
+	if len(byHost) == 0 {
+		return nil, fmt.Errorf("no <host>__<command>.txt file of a supported command in %s", dir)
+	}
+
+	hosts := make([]string, 0, len(byHost))
+	for host := range byHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	results := make([]DeviceResult, 0, len(hosts))
+	for _, host := range hosts {
+		device, ok := devices[host]
+		if !ok {
+			device = Device{Host: host}
+			log.Printf("Host %s is not in the inventory, replaying without a platform", host)
+		}
+		outputs := byHost[host]
+		// Descriptions override the other commands when merged; run them last as a live run would
+		sort.SliceStable(outputs, func(i, j int) bool {
+			return !isDescriptionCommand(outputs[i].Command) && isDescriptionCommand(outputs[j].Command)
+		})
+
+		result := DeviceResult{Device: device, Outputs: outputs, Started: time.Now()}
+		result.Rows = ParseOutputs(outputs, device)
+		result.Status = DeviceStatus{Host: device.Host, Platform: device.Platform, Transport: "replay", Interfaces: len(result.Rows)}
+		for _, output := range outputs {
+			if output.Err != nil && result.Err == nil {
+				result.Err = output.Err
+			}
+		}
+		if result.Err == nil && len(result.Rows) == 0 {
+			result.Err = errNoRows
+		}
+		result.Status.Class = ClassifyError(result.Err)
+		switch {
+		case result.Err == nil:
+			result.Status.Status = StatusOK
+		case len(result.Rows) > 0:
+			result.Status.Status = StatusPartial
+		default:
+			result.Status.Status = StatusFailed
+		}
+		if result.Err != nil {
+			result.Status.Error = result.Err.Error()
+		}
+		log.Printf("Replayed %d commands for %s: %d interfaces (%s)", len(outputs), device.Host, len(result.Rows), result.Status.Status)
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	runIDLayout        = "02012006-150405.000" // DDMMYYYY-HHMMSS.mmm, no ':' which Excel does not allow in sheet names
+	runIDSuffixLength  = 4                     // Random hex digits appended to the start time
+	maxSheetNameLength = 31                    // Longest sheet name Excel accepts
+)
+
+// NewRunID returns the ID of a run started at the given time, naming the audit sheet the run writes. The milliseconds
+// and the random suffix keep apart the runs started together, e.g. by a script auditing several inventories.
+func NewRunID(start time.Time) string {
+	return fmt.Sprintf("%s-%0*x", start.Format(runIDLayout), runIDSuffixLength, rand.Intn(1<<(4*runIDSuffixLength)))
+}
+
+// AuditSheetName returns the name of the audit sheet of a run, "Audit DDMMYYYY-HHMMSS.mmm-xxxx", cut to Excel's 31 characters.
+func AuditSheetName(runID string) string {
+	name := fmt.Sprintf("Audit %s", runID)
+	if len(name) > maxSheetNameLength {
+		name = name[:maxSheetNameLength]
+	}
+	return name
+}
+
+// runIDTime returns the start time of a run from its ID, "DDMMYYYY-HHMMSS.mmm-xxxx", or "DDMMYYYY-HHMMSS" and "DDMMYYYY"
+// for the runs of earlier versions.
+func runIDTime(runID string) (time.Time, bool) {
+	// The random suffix is not part of the time
+	if len(runID) == len(runIDLayout)+1+runIDSuffixLength {
+		runID = runID[:len(runIDLayout)]
+	}
+	for _, layout := range []string{runIDLayout, "02012006-150405", "02012006"} {
+		if start, err := time.ParseInLocation(layout, runID, time.Local); err == nil {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunIDsOfRunsStartedTogetherDiffer(t *testing.T) {
+	start := time.Date(2026, 10, 18, 9, 5, 7, 123_000_000, time.Local)
+	first, second := NewRunID(start), NewRunID(start.Add(time.Millisecond))
+	if first == second {
+		t.Fatalf("runs started 1ms apart share the run ID %q", first)
+	}
+	ids := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		ids[NewRunID(start)] = true
+	}
+	if len(ids) < 2 {
+		t.Errorf("runs started at the same time share the run ID %q", first)
+	}
+	name := AuditSheetName(first)
+	if !strings.HasPrefix(name, "Audit 18102026-090507.123-") || len(name) != len("Audit 18102026-090507.123-")+runIDSuffixLength {
+		t.Errorf("AuditSheetName = %q, want \"Audit 18102026-090507.123-xxxx\"", name)
+	}
+	if len(name) > maxSheetNameLength || strings.ContainsAny(name, `:\/?*[]`) {
+		t.Errorf("%q is not a valid Excel sheet name", name)
+	}
+
+	// The sheets of this and earlier versions are dated, so -reference previous and date: find them
+	for sheet, want := range map[string]time.Time{
+		name:                    start,
+		"Audit 18102026-090507": start.Truncate(time.Second),
+		"Audit 18102026":        time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local),
+	} {
+		if date, ok := auditSheetDate(sheet); !ok || !date.Equal(want) {
+			t.Errorf("auditSheetDate(%q) = %v, %t, want %v", sheet, date, ok, want)
+		}
+	}
+}
@@ -3,18 +3,72 @@ package internal
 import (
 	"flag"
 	"fmt"
+	"github.com/pterm/pterm"
+	"golang.org/x/term"
+	"io"
 	"os"
+	"strings"
 )
 
-// SetupFlags parses the command-line flags and returns their values.
-func SetupFlags() (username, password, filePath *string, baseFile, generateInv, usageGuide *bool, err error) {
+/*
+SetupFlags parses the command-line flags and returns the resulting run configuration.
+
+Values are resolved in the following order: built-in defaults, the YAML configuration file given with -config,
+and finally the command-line flags, so a flag always overrides the configuration file.
+*/
+func SetupFlags() (*Config, error) {
+	cfg := DefaultConfig()
+
+	// Load the configuration file first so its values become the flag defaults
+	cfg.ConfigFile = findConfigPath(os.Args[1:])
+	if cfg.ConfigFile != "" {
+		if err := LoadConfigFile(cfg.ConfigFile, cfg); err != nil {
+			return cfg, err
+		}
+	}
+
 	// Define flags
-	usageGuide = flag.Bool("usage", false, "Display the usage guide")
-	username = flag.String("u", "", "Username for device access")
-	password = flag.String("p", "", "Password for device access")
-	filePath = flag.String("f", "", "File path")
-	baseFile = flag.Bool("base", false, "Create initial Excel file with a baseline sheet")
-	generateInv = flag.Bool("gen", false, "Generate a YAML inventory file from a list of devices")
+	flag.BoolVar(&cfg.UsageGuide, "usage", false, "Display the usage guide")
+	flag.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "Path to a YAML configuration file")
+	flag.StringVar(&cfg.Username, "u", cfg.Username, "Username for device access")
+	flag.StringVar(&cfg.Password, "p", "", "Password for device access (visible in shell history and ps, prefer the prompt, PORT_AUDIT_PASSWORD or -password-file)")
+	flag.StringVar(&cfg.PasswordFile, "password-file", cfg.PasswordFile, "File holding the password for device access")
+	flag.StringVar(&cfg.KeyFile, "key", cfg.KeyFile, "Private key file for SSH public key authentication")
+	flag.BoolVar(&cfg.UseAgent, "agent", cfg.UseAgent, "Authenticate with the SSH agent (SSH_AUTH_SOCK)")
+	flag.StringVar(&cfg.InventoryFile, "f", cfg.InventoryFile, "File path")
+	flag.BoolVar(&cfg.BaseFile, "base", cfg.BaseFile, "Create initial Excel file with a baseline sheet")
+	flag.BoolVar(&cfg.GenerateInv, "gen", false, "Generate a YAML inventory file from a list of devices")
+	flag.StringVar(&cfg.Profile, "profile", cfg.Profile, "Collection profile: auto (command by platform), status, description or full (status and description joined per port)")
+	flag.StringVar(&cfg.Command, "command", cfg.Command, "Commands to run on every device, separated by commas, overrides -profile (e.g. \"show interface status\")")
+	flag.StringVar(&cfg.TemplateDir, "templates", cfg.TemplateDir, "Directory of TextFSM templates with an ntc-templates style index, used before the built-in parsers")
+	flag.BoolVar(&cfg.NoColor, "no-color", cfg.NoColor, "Disable coloured screen output")
+	flag.StringVar(&cfg.HostKeyMode, "host-key", cfg.HostKeyMode, "Host key verification: strict (known_hosts only), tofu (record new keys) or insecure (no verification)")
+	flag.StringVar(&cfg.KnownHostsFile, "known-hosts", cfg.KnownHostsFile, "known_hosts file used by port-audit, new keys are recorded here in tofu mode")
+	flag.StringVar(&cfg.ExecMode, "exec-mode", cfg.ExecMode, "How commands are run: auto (by platform), shell (interactive PTY) or exec")
+	flag.DurationVar(&cfg.CommandTimeout, "command-timeout", cfg.CommandTimeout, "Maximum time a single command may take in shell mode")
+	flag.DurationVar(&cfg.DialTimeout, "dial-timeout", cfg.DialTimeout, "Maximum time to establish a connection to a device or jump host")
+	flag.IntVar(&cfg.Retries, "retries", cfg.Retries, "Extra attempts for devices failing with DNS, refused connection or timeout errors")
+	flag.DurationVar(&cfg.RetryBackoff, "retry-backoff", cfg.RetryBackoff, "Wait before the first retry, doubled for every further retry")
+	flag.StringVar(&cfg.ResultsFile, "results", cfg.ResultsFile, "File receiving the per-device results as JSON")
+	flag.IntVar(&cfg.Workers, "workers", cfg.Workers, "Number of devices processed concurrently")
+	flag.Float64Var(&cfg.ConnectRate, "connect-rate", cfg.ConnectRate, "New device connections per second across the run, 0 for unlimited")
+	flag.IntVar(&cfg.ConnectBurst, "connect-burst", cfg.ConnectBurst, "Connections allowed back to back before -connect-rate applies")
+	flag.IntVar(&cfg.GroupSessions, "group-sessions", cfg.GroupSessions, "Concurrent sessions per inventory group without its own max_sessions, 0 for unlimited")
+	flag.DurationVar(&cfg.RunTimeout, "run-timeout", cfg.RunTimeout, "Deadline of the whole run (e.g. 30m), 0 for none; devices not audited in time are reported as not collected")
+	flag.StringVar(&cfg.RawOutputDir, "raw-dir", cfg.RawOutputDir, "Directory receiving the raw output of every command, archived with the reports (empty to disable)")
+	flag.StringVar(&cfg.ReplayDir, "replay", cfg.ReplayDir, "Parse the saved <host>__<command>.txt output of this directory instead of connecting to devices")
+	flag.BoolVar(&cfg.NonInteractive, "non-interactive", cfg.NonInteractive, "Never prompt for input (implied when stdin is not a terminal)")
+
+	var compareFields, compareRules string
+	flag.StringVar(&compareFields, "compare-fields", strings.Join(cfg.Compare.Fields, ","), "Fields compared against the reference: description, status, vlan, speed, duplex, type, slot, port")
+	flag.StringVar(&compareRules, "compare-rules", "", "Comparison rule per field, e.g. \"description=whitespace,vlan=exact+ignore-blank\" (exact, case-insensitive, whitespace, regex, ignore-blank)")
+
+	flag.StringVar(&cfg.Reference, "reference", cfg.Reference, "Sheet the audit is compared against: baseline, previous (latest earlier Audit sheet), sheet:<name> or date:<YYYY-MM-DD>")
+	flag.StringVar(&cfg.ReferenceFile, "reference-file", cfg.ReferenceFile, "Workbook holding the reference sheet, if not the audit workbook PortAudit.xlsx")
+	flag.StringVar(&cfg.WaiversFile, "waivers", cfg.WaiversFile, "YAML file of waivers suppressing known findings (owner, reason, ticket and expiry per waiver)")
+
+	var jump string
+	flag.StringVar(&jump, "jump", "", "Jump hosts for every device without its own, ProxyJump style (e.g. admin@bastion:22,bastion2)")
 
 	// Custom usage message
 	flag.Usage = func() {
@@ -25,32 +79,158 @@ func SetupFlags() (username, password, filePath *string, baseFile, generateInv,
 	// Parse the command-line flags
 	flag.Parse()
 
+	if jump != "" {
+		hops, err := ParseJumpHosts(jump)
+		if err != nil {
+			return cfg, fmt.Errorf("error: %v", err)
+		}
+		cfg.JumpHosts = hops
+	}
+
+	// The comparison policy, the reference and the waivers also apply to a replay
+	if err := ValidateReference(cfg.Reference); err != nil {
+		return cfg, fmt.Errorf("error: %v", err)
+	}
+	cfg.Compare.Fields = SplitCommands(compareFields)
+	if err := cfg.Compare.SetRules(compareRules); err != nil {
+		return cfg, fmt.Errorf("error: %v", err)
+	}
+	if err := cfg.Compare.Prepare(); err != nil {
+		return cfg, fmt.Errorf("error: %v", err)
+	}
+	if cfg.WaiversFile != "" {
+		waivers, err := LoadWaivers(cfg.WaiversFile)
+		if err != nil {
+			return cfg, fmt.Errorf("error: %v", err)
+		}
+		cfg.Waivers = waivers
+	}
+
+	// Load the templates first, so the commands they parse are accepted by the validation and the replay
+	if cfg.TemplateDir != "" {
+		if _, err := LoadTemplates(cfg.TemplateDir); err != nil {
+			return cfg, fmt.Errorf("error: %v", err)
+		}
+	}
+
+	// Only prompt when a user is attached to stdin
+	cfg.Interactive = !cfg.NonInteractive && term.IsTerminal(int(os.Stdin.Fd()))
+
 	// Return if only the usage guide flag is set
-	if *usageGuide {
-		return
+	if cfg.UsageGuide {
+		return cfg, nil
 	}
 
 	// If the generate inventory flag is set and an inventory file is provided, return
-	if *generateInv && *filePath != "" {
-		return
+	if cfg.GenerateInv && cfg.InventoryFile != "" {
+		return cfg, nil
+	}
+
+	// A replay contacts no device: no credentials are needed and the inventory only provides platform hints
+	if cfg.ReplayDir != "" {
+		return cfg, nil
+	}
+
+	// The command-line credentials are only needed by the devices and jump hosts without their own
+	cfg.GlobalUsername, cfg.GlobalCredentials = true, true
+	if cfg.InventoryFile != "" {
+		inventory, err := ReadInventory(cfg.InventoryFile, pterm.DefaultLogger.WithWriter(io.Discard))
+		if err != nil {
+			return cfg, fmt.Errorf("error: %v", err)
+		}
+		cfg.GlobalUsername, cfg.GlobalCredentials = NeedsGlobalCredentials(inventory, cfg.JumpHosts)
+	}
+
+	// Resolve the password from the most explicit source available
+	if err := resolveConfigPassword(cfg); err != nil {
+		return cfg, err
 	}
 
 	// Validate the input flags for all other cases
-	err = validateFlags(username, password, filePath)
-	return
+	return cfg, validateFlags(cfg)
+}
+
+/*
+Fill in the password of the run when it was not given with -p.
+
+Sources are tried in order: -p, -password-file, the PORT_AUDIT_PASSWORD environment variable and finally a prompt
+without echo. The prompt is only shown when stdin is a terminal, no private key or SSH agent is configured and at
+least one device or jump host of the inventory has no authentication method of its own.
+*/
+func resolveConfigPassword(cfg *Config) error {
+	switch {
+	case cfg.Password != "":
+		cfg.PasswordSource = "flag"
+	case cfg.PasswordFile != "":
+		password, err := readPasswordFile(cfg.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("error: %v", err)
+		}
+		cfg.Password, cfg.PasswordSource = password, "file"
+	case os.Getenv("PORT_AUDIT_PASSWORD") != "":
+		cfg.Password, cfg.PasswordSource = os.Getenv("PORT_AUDIT_PASSWORD"), "env"
+	case cfg.Interactive && cfg.GlobalCredentials && cfg.KeyFile == "" && !cfg.UseAgent:
+		fmt.Fprintf(os.Stderr, "Password for %s: ", cfg.Username)
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("error: Failed to read password: %v", err)
+		}
+		cfg.Password, cfg.PasswordSource = string(password), "prompt"
+	}
+	return nil
 }
 
 // validateFlags checks if necessary flags are provided and returns an error if any are missing.
-func validateFlags(username, password, filePath *string) error {
+func validateFlags(cfg *Config) error {
 	// Validate required flags
-	if *username == "" {
-		return fmt.Errorf("error: Username is required. Please provide a username with --u (e.g., --u admin)")
+	if cfg.InventoryFile == "" {
+		return fmt.Errorf("error: Inventory file is required. Please provide a file with --f (e.g., --f ./Inventory.yml)")
 	}
-	if *password == "" {
-		return fmt.Errorf("error: Password is required. Please provide a password with --p (e.g., --p password)")
+	// A username is only needed when a device or jump host has none of its own
+	if cfg.GlobalUsername && cfg.Username == "" {
+		return fmt.Errorf("error: Username is required. Please provide a username with --u (e.g., --u admin), or give every device and jump host its own username in the inventory")
 	}
-	if *filePath == "" {
-		return fmt.Errorf("error: Inventory file is required. Please provide a file with --f (e.g., --f ./Inventory.yml)")
+	// A password is only needed when a device or jump host has no other authentication method
+	if cfg.GlobalCredentials && cfg.Password == "" && cfg.KeyFile == "" && !cfg.UseAgent {
+		return fmt.Errorf("error: Password is required. Please run from a terminal to be prompted, set PORT_AUDIT_PASSWORD, use --password-file, a private key with --key or --agent, or give every device its own credentials in the inventory")
+	}
+	switch cfg.HostKeyMode {
+	case HostKeyStrict, HostKeyTOFU, HostKeyInsecure:
+	default:
+		return fmt.Errorf("error: Unknown host key mode %q. Please use --host-key strict, tofu or insecure", cfg.HostKeyMode)
+	}
+	switch cfg.ExecMode {
+	case ExecModeAuto, ExecModeShell, ExecModeExec:
+	default:
+		return fmt.Errorf("error: Unknown execution mode %q. Please use --exec-mode auto, shell or exec", cfg.ExecMode)
+	}
+	if cfg.DialTimeout <= 0 {
+		return fmt.Errorf("error: Dial timeout must be positive (e.g., --dial-timeout 5s)")
+	}
+	if cfg.Retries < 0 || cfg.RetryBackoff < 0 {
+		return fmt.Errorf("error: Retries and retry backoff cannot be negative")
+	}
+	if cfg.Workers < 1 {
+		return fmt.Errorf("error: At least one worker is required (e.g., --workers 10)")
+	}
+	if cfg.ConnectRate < 0 || cfg.ConnectBurst < 1 || cfg.GroupSessions < 0 {
+		return fmt.Errorf("error: Connection rate and group sessions cannot be negative and the connection burst must be at least 1")
+	}
+	if cfg.RunTimeout < 0 {
+		return fmt.Errorf("error: Run timeout cannot be negative (e.g., --run-timeout 30m)")
+	}
+	if cfg.CommandTimeout <= 0 {
+		return fmt.Errorf("error: Command timeout must be positive (e.g., --command-timeout 60s)")
+	}
+	if cfg.Command != "" {
+		for _, command := range SplitCommands(cfg.Command) {
+			if !CommandSupported(command) {
+				return fmt.Errorf("error: Unsupported command %q. Supported commands: %s, or those of the -templates index", command, strings.Join(SupportedCommands(), ", "))
+			}
+		}
+	} else if _, ok := collectionProfiles[cfg.Profile]; !ok && cfg.Profile != AutoProfile {
+		return fmt.Errorf("error: Unknown profile %q. Supported profiles: %s, %s", cfg.Profile, AutoProfile, joinSorted(collectionProfiles))
 	}
 
 	return nil
@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// promptPattern matches a CLI prompt such as "switch#", "switch>" or "RP/0/RSP0/CPU0:router#" at the end of the output.
+	promptPattern = regexp.MustCompile(`[\w.\-@/:()\[\]~]+[>#]\s*$`)
+	// morePattern matches a pager prompt left in the output when paging could not be disabled.
+	morePattern = regexp.MustCompile(` *-+ *[Mm]ore *-+ *`)
+	// controlPattern matches ANSI escape sequences and backspaces written around pager prompts.
+	controlPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]|\x08+ *\x08*`)
+)
+
+/*
+ShellExecutor drives an interactive CLI session, either an SSH shell on a PTY, for platforms whose exec channels reject
+or truncate commands, or a telnet session (see DialTelnet).
+
+The executor detects the device prompt when it starts, disables paging with the platform's pager command and then
+reads the output of each command until the prompt comes back.
+*/
+type ShellExecutor struct {
+	stdin   io.Writer
+	closer  io.Closer // The SSH session or telnet connection, closed with the executor
+	timeout time.Duration
+	prompt  string
+
+	mu      sync.Mutex
+	buffer  strings.Builder
+	readErr error
+	notify  chan struct{}
+}
+
+/*
+Start an interactive shell on the session and prepare it for running commands.
+
+Parameters:
+  - session *ssh.Session: The SSH session opened for the device.
+  - pagerCommand string: The command disabling paging (e.g. "terminal length 0"), skipped when empty.
+  - timeout time.Duration: How long to wait for the prompt after each command.
+
+Returns:
+  - *ShellExecutor: The executor, positioned at the device prompt.
+  - error: Returned if the PTY or shell cannot be started, or the prompt is not detected in time.
+*/
+
+func NewShellExecutor(session *ssh.Session, pagerCommand string, timeout time.Duration) (*ShellExecutor, error) {
+	// A wide terminal keeps long lines from wrapping
+	if err := session.RequestPty("vt100", 0, 511, ssh.TerminalModes{}); err != nil {
+		return nil, fmt.Errorf("failed to request PTY: %v", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shell input: %v", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shell output: %v", err)
+	}
+	if err := session.Shell(); err != nil {
+		return nil, fmt.Errorf("failed to start shell: %v", err)
+	}
+
+	e := newShellExecutor(stdin, stdout, session, timeout)
+	if err := e.prepare(pagerCommand); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// newShellExecutor creates an executor over an interactive byte stream and starts reading its output.
+func newShellExecutor(stdin io.Writer, stdout io.Reader, closer io.Closer, timeout time.Duration) *ShellExecutor {
+	e := &ShellExecutor{stdin: stdin, closer: closer, timeout: timeout, notify: make(chan struct{}, 1)}
+	go e.read(stdout)
+	return e
+}
+
+// prepare detects the device prompt and disables paging, leaving the CLI ready for commands.
+func (e *ShellExecutor) prepare(pagerCommand string) error {
+	// Wake the CLI up and wait for the prompt
+	if _, err := io.WriteString(e.stdin, "\n"); err != nil {
+		return fmt.Errorf("failed to write to shell: %v", err)
+	}
+	output, err := e.readUntil(func(output string) bool { return promptPattern.MatchString(output) }, nil)
+	if err != nil {
+		return fmt.Errorf("device prompt not detected: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(output, "\r", ""), " \n"), "\n")
+	e.prompt = strings.TrimSpace(lines[len(lines)-1])
+	log.Printf("Detected device prompt %q", e.prompt)
+
+	if pagerCommand != "" {
+		if _, err := e.Run(pagerCommand); err != nil {
+			return fmt.Errorf("failed to disable paging with %q: %v", pagerCommand, err)
+		}
+	}
+	return nil
+}
+
+// Run sends a command and returns its output without the command echo and the trailing prompt. Output received before
+// the echo of the command, such as the prompt answering the wake-up newline of prepare, belongs to earlier input and
+// never ends the command.
+func (e *ShellExecutor) Run(command string) (string, error) {
+	if _, err := io.WriteString(e.stdin, command+"\n"); err != nil {
+		return "", fmt.Errorf("failed to send command: %v", err)
+	}
+
+	afterEcho := func(output string) (int, bool) {
+		echo := strings.Index(output, command)
+		return echo + len(command), echo >= 0
+	}
+	done := func(output string) bool {
+		start, ok := afterEcho(output)
+		return ok && strings.HasSuffix(strings.TrimRight(output[start:], " \n"), e.prompt)
+	}
+	// Paging is still on when the output ends with a More prompt: its end tells one prompt from the next
+	more := func(output string) int {
+		start, ok := afterEcho(output)
+		if !ok {
+			return -1
+		}
+		// The prompts already answered stay in the output, only the last one can end it
+		trimmed := strings.TrimRight(output[start:], " \n")
+		if locs := morePattern.FindAllStringIndex(trimmed, -1); len(locs) > 0 && locs[len(locs)-1][1] == len(trimmed) {
+			return start + len(trimmed)
+		}
+		return -1
+	}
+	output, err := e.readUntil(done, more)
+	if err != nil {
+		return cleanShellOutput(output, command, e.prompt), fmt.Errorf("command %q did not complete: %v", command, err)
+	}
+	return cleanShellOutput(output, command, e.prompt), nil
+}
+
+// Close leaves the CLI and closes the shell session or telnet connection; an SSH client is owned by the caller.
+func (e *ShellExecutor) Close() error {
+	io.WriteString(e.stdin, "exit\n")
+	return e.closer.Close()
+}
+
+// read copies the shell output into the buffer and signals every new chunk.
+func (e *ShellExecutor) read(stdout io.Reader) {
+	chunk := make([]byte, 4096)
+	for {
+		n, err := stdout.Read(chunk)
+		e.mu.Lock()
+		e.buffer.Write(chunk[:n])
+		if err != nil {
+			e.readErr = err
+		}
+		e.mu.Unlock()
+
+		select {
+		case e.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readUntil waits until done reports true for the output received so far, then consumes and returns it. On a timeout
+// the partial output is consumed too, so the next command does not start with it.
+//
+// When more is set, it returns the end of the pager prompt ending the output, or -1, and each new pager prompt is
+// answered with a single space: a prompt still at the end of the output after its answer, e.g. because the device
+// echoed the space, is not answered again, which would skip a page.
+func (e *ShellExecutor) readUntil(done func(output string) bool, more func(output string) int) (string, error) {
+	timer := time.NewTimer(e.timeout)
+	defer timer.Stop()
+
+	answered := -1 // End of the last pager prompt answered
+	for {
+		e.mu.Lock()
+		output, readErr := e.buffer.String(), e.readErr
+		e.mu.Unlock()
+
+		stripped := strings.ReplaceAll(output, "\r", "")
+		if done(stripped) {
+			e.mu.Lock()
+			remainder := e.buffer.String()[len(output):] // Keep anything received after the check
+			e.buffer.Reset()
+			e.buffer.WriteString(remainder)
+			e.mu.Unlock()
+			return output, nil
+		}
+		if readErr != nil {
+			return output, fmt.Errorf("shell closed: %v", readErr)
+		}
+		if more != nil {
+			if end := more(stripped); end > answered {
+				answered = end
+				if _, err := io.WriteString(e.stdin, " "); err != nil {
+					return output, fmt.Errorf("failed to answer the pager: %v", err)
+				}
+			}
+		}
+
+		select {
+		case <-e.notify:
+		case <-timer.C:
+			e.mu.Lock()
+			output = e.buffer.String()
+			e.buffer.Reset()
+			e.mu.Unlock()
+			return output, fmt.Errorf("timed out after %s", e.timeout)
+		}
+	}
+}
+
+// cleanShellOutput removes carriage returns, pager artefacts, the command echo with anything before it and the
+// trailing prompt.
+func cleanShellOutput(output, command, prompt string) string {
+	output = strings.ReplaceAll(output, "\r", "")
+	output = controlPattern.ReplaceAllString(output, "")
+
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		if strings.Contains(line, command) {
+			lines = lines[i+1:] // Command echo
+			break
+		}
+	}
+	if n := len(lines); n > 0 && strings.HasSuffix(strings.TrimSpace(lines[n-1]), prompt) {
+		lines = lines[:n-1] // Prompt
+	}
+
+	cleaned := make([]string, 0, len(lines))
+	for _, line := range lines {
+		cleaned = append(cleaned, morePattern.ReplaceAllString(line, ""))
+	}
+	return strings.Join(cleaned, "\n")
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCLI answers every line like a Cisco CLI on a PTY: it echoes the line, prints the output of the command and a new
+// prompt, and prints a banner and a prompt when the shell starts. Commands of hang print partial output and no prompt.
+func fakeCLI(t *testing.T, outputs map[string]string, hang string) *ShellExecutor {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	go func() {
+		defer outWriter.Close()
+		io.WriteString(outWriter, "\r\nUnauthorised access prohibited\r\n\r\nswitch#")
+		lines := bufio.NewScanner(inReader)
+		for lines.Scan() {
+			command := strings.TrimSpace(lines.Text())
+			switch {
+			case command == "exit":
+				return
+			case hang != "" && command == hang:
+				io.WriteString(outWriter, command+"\r\npartial output\r\n")
+			default:
+				io.WriteString(outWriter, command+"\r\n"+strings.ReplaceAll(outputs[command], "\n", "\r\n")+"switch#")
+			}
+		}
+	}()
+	e := newShellExecutor(inWriter, outReader, inReader, 500*time.Millisecond)
+	t.Cleanup(func() { e.Close() })
+	if err := e.prepare("terminal length 0"); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	return e
+}
+
+func TestShellExecutorRunsEachCommandOnItsOwnOutput(t *testing.T) {
+	outputs := map[string]string{
+		"terminal length 0":                "",
+		"show interface status":            "Port      Name   Status\nGi1/0/1   uplink connected\n",
+		"show interface description":       "Interface Status Protocol Description\nGi1/0/1   up     up       uplink\n",
+		"show running-config | i hostname": "hostname switch\n",
+	}
+	e := fakeCLI(t, outputs, "")
+	if e.prompt != "switch#" {
+		t.Fatalf("prompt = %q, want %q", e.prompt, "switch#")
+	}
+	for _, command := range []string{"show interface status", "show interface description", "show running-config | i hostname"} {
+		output, err := e.Run(command)
+		if err != nil {
+			t.Fatalf("Run(%q): %v", command, err)
+		}
+		if want := strings.TrimRight(outputs[command], "\n"); output != want {
+			t.Errorf("Run(%q) = %q, want %q", command, output, want)
+		}
+	}
+}
+
+func TestShellExecutorDropsOutputOfTimedOutCommand(t *testing.T) {
+	outputs := map[string]string{"terminal length 0": "", "show version": "Cisco IOS Software\n"}
+	e := fakeCLI(t, outputs, "show tech-support")
+	if _, err := e.Run("show tech-support"); err == nil {
+		t.Fatal("Run of a command without prompt returned no error")
+	}
+	output, err := e.Run("show version")
+	if err != nil {
+		t.Fatalf("Run after timeout: %v", err)
+	}
+	if output != "Cisco IOS Software" {
+		t.Errorf("Run after timeout = %q, want %q", output, "Cisco IOS Software")
+	}
+}
+
+// pagedCLI is a CLI whose paging cannot be disabled: command prints the pages one by one, each but the last followed
+// by a More prompt. The device echoes the space answering a prompt before erasing the prompt in two writes and
+// printing the next page. It returns the executor and the number of spaces received by the device.
+func pagedCLI(t *testing.T, command string, pages []string) (*ShellExecutor, func() int) {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	var mu sync.Mutex
+	spaces := 0
+	go func() {
+		defer outWriter.Close()
+		io.WriteString(outWriter, "\r\nswitch#")
+		input := bufio.NewReader(inReader)
+		line, page := "", 0
+		for {
+			b, err := input.ReadByte()
+			if err != nil {
+				return
+			}
+			switch {
+			case b == ' ' && page > 0:
+				mu.Lock()
+				spaces++
+				mu.Unlock()
+				io.WriteString(outWriter, " ")
+				time.Sleep(20 * time.Millisecond)
+				io.WriteString(outWriter, "\x08\x08\x08\x08\x08\x08\x08\x08\x08\x08")
+				io.WriteString(outWriter, "          \x08\x08\x08\x08\x08\x08\x08\x08\x08\x08")
+				if page == len(pages)-1 {
+					io.WriteString(outWriter, pages[page]+"switch#")
+					page = 0
+				} else {
+					io.WriteString(outWriter, pages[page]+" --More-- ")
+					page++
+				}
+			case b == ' ' && line == "":
+				mu.Lock()
+				spaces++ // A space nobody asked for
+				mu.Unlock()
+			case b == '\n':
+				switch line {
+				case "exit":
+					return
+				case command:
+					io.WriteString(outWriter, line+"\r\n"+pages[0]+" --More-- ")
+					page = 1
+				default:
+					io.WriteString(outWriter, line+"\r\nswitch#")
+				}
+				line = ""
+			default:
+				line += string(b)
+			}
+		}
+	}()
+	e := newShellExecutor(inWriter, outReader, inReader, time.Second)
+	t.Cleanup(func() { e.Close() })
+	if err := e.prepare("terminal length 0"); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	return e, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return spaces
+	}
+}
+
+func TestShellExecutorAnswersEachMorePromptOnce(t *testing.T) {
+	pages := []string{"Gi1/0/1 uplink\r\nGi1/0/2 spare\r\n", "Gi1/0/3 printer\r\nGi1/0/4 camera\r\n", "Gi1/0/5 ap\r\n"}
+	e, spaces := pagedCLI(t, "show interfaces status", pages)
+	output, err := e.Run("show interfaces status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, port := range []string{"Gi1/0/1 uplink", "Gi1/0/2 spare", "Gi1/0/3 printer", "Gi1/0/4 camera", "Gi1/0/5 ap"} {
+		if !strings.Contains(output, port) {
+			t.Errorf("output misses %q:\n%q", port, output)
+		}
+	}
+	if _, err := e.Run("show clock"); err != nil {
+		t.Fatal(err)
+	}
+	if n := spaces(); n != len(pages)-1 {
+		t.Errorf("device received %d spaces, want one per More prompt, %d", n, len(pages)-1)
+	}
+}
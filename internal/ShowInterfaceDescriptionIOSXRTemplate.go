@@ -0,0 +1,14 @@
+package internal
+
+// showInterfaceDescriptionIOSXRTemplate parses 'show int description' on IOS-XR, keeping the part of the description
+// enclosed in "##", e.g.
+//
+//	TenGigE0/0/0/1 is up , up ##Uplink to core## circuit 42
+const showInterfaceDescriptionIOSXRTemplate = `Value PORT ((?:Te|GigabitEthernet|TenGigE|Eth|Ge|Ethernet)[\w/]+)
+Value STATUS (up|down|administratively down|admin down|connected|disabled)
+Value PROTOCOL (up|down)
+Value DESCRIPTION (\#\#.*?\#\#)
+
+Start
+  ^${PORT}\s+is\s+${STATUS}\s+,\s+${PROTOCOL}\s+${DESCRIPTION}\s*.*$$ -> Record
+`
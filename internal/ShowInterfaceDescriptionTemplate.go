@@ -0,0 +1,15 @@
+package internal
+
+// showIntDescriptionTemplate parses 'show interface description' on IOS and IOS-XE, e.g.
+//
+//	Gi1/0/1                        up             up       Uplink to core
+//
+// The status and the line protocol are combined into the Status field, e.g. "up (up)". The description may be empty.
+const showIntDescriptionTemplate = `Value PORT (\S+)
+Value STATUS (admin down|down|up)
+Value PROTOCOL (down|up)
+Value DESCRIPTION (.*)
+
+Start
+  ^${PORT}\s+${STATUS}\s+${PROTOCOL}\s*${DESCRIPTION}$$ -> Record
+`
@@ -0,0 +1,19 @@
+package internal
+
+// showIntStatusTemplate parses 'show interface status' on IOS, IOS-XE and NX-OS, e.g.
+//
+//	Gi1/0/1   Uplink to core   connected    trunk      a-full a-1000 10/100/1000BaseTX
+//
+// The name is matched non-greedily as it may be empty or contain spaces. A duplex or speed prefixed with "a-" was
+// auto-negotiated. The type is captured until the end of the line as it may contain spaces (e.g. "Not Present").
+const showIntStatusTemplate = `Value PORT ((?:GigabitEthernet|TenGigabitEthernet|Eth|Ge|Gi|Te|Fa)\d+(?:/\d+)+)
+Value NAME (.*?)
+Value STATUS (up|down|administratively down|admin down|connected|notconnect|disabled|err-disabled|inactive|sfpAbsent|xcvrAbsent|monitoring|suspended)
+Value VLAN_ID (\d+|routed|trunk|unassigned)
+Value DUPLEX ((?:a-)?full|(?:a-)?half|auto)
+Value SPEED (\S+)
+Value TYPE (.*)
+
+Start
+  ^${PORT}\s+${NAME}\s+${STATUS}\s+${VLAN_ID}\s+${DUPLEX}\s+${SPEED}\s+${TYPE}$$ -> Record
+`
@@ -1,14 +1,17 @@
 package internal
 
-import (
-	"strings"
-)
+/*
+Split an interface name into the SLOT and PORT columns of the report.
+
+Parameters:
+  - interfaceName string: The interface name, e.g. "GigabitEthernet1/0/24", "Te0/0/0/1" or "Po10".
+
+Returns:
+  - string: The slot, e.g. "1/0" (see InterfaceName.SlotColumn); empty for names without slot.
+  - string: The port, e.g. "24" (see InterfaceName.PortColumn); both are empty if the name cannot be parsed.
+*/
 
-// Split interface into slot and port
 func ParseSlotAndPort(interfaceName string) (string, string) {
-	parts := strings.Split(interfaceName, "/")
-	if len(parts) > 1 {
-		return parts[0][2:], parts[1] // Assumes interface names start with "Gi", "Te", etc.
-	}
-	return "", "" // Return empty strings if not parsable
+	name := ParseInterfaceName(interfaceName)
+	return name.SlotColumn(), name.PortColumn()
 }
@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Transports supported in Device.Transport.
+const (
+	TransportSSH    = "ssh"
+	TransportTelnet = "telnet" // Cleartext: credentials and output can be read on the network
+)
+
+// Telnet protocol bytes (RFC 854).
+const (
+	telnetIAC  = 255
+	telnetDONT = 254
+	telnetDO   = 253
+	telnetWONT = 252
+	telnetWILL = 251
+	telnetSB   = 250
+	telnetSE   = 240
+
+	telnetOptionEcho = 1
+	telnetOptionSGA  = 3 // Suppress go-ahead
+)
+
+var (
+	usernamePromptPattern = regexp.MustCompile(`(?i)(username|login)\s*:\s*$`)
+	passwordPromptPattern = regexp.MustCompile(`(?i)password\s*:\s*$`)
+	loginFailedPattern    = regexp.MustCompile(`(?i)(login invalid|authentication failed|access denied|login incorrect)`)
+)
+
+/*
+Open a telnet session to a device, log in and prepare the CLI for running commands.
+
+Parameters:
+  - ctx context.Context: The run context; cancelling it aborts the connection and the login.
+  - host string: The IP address or hostname of the network device.
+  - port int: The telnet port of the device.
+  - opts AuthOptions: The credentials; only the username and password are used over telnet.
+  - pagerCommand string: The command disabling paging, skipped when empty.
+  - dialTimeout time.Duration: Maximum time to establish the TCP connection.
+  - timeout time.Duration: How long to wait for each prompt, including the login prompts.
+  - dial DialFunc: Opens the connection through a jump host; nil dials the device directly.
+
+Returns:
+  - Executor: A shell executor positioned at the device prompt.
+  - error: Returned if the connection or login fails.
+*/
+
+func DialTelnet(ctx context.Context, host string, port int, opts AuthOptions, pagerCommand string, dialTimeout, timeout time.Duration, dial DialFunc) (Executor, error) {
+	if opts.Password == "" {
+		return nil, fmt.Errorf("telnet requires a password for user %s", opts.Username)
+	}
+
+	log.Printf("WARNING: Attempting cleartext telnet connection to %s:%d with user %s", host, port, opts.Username)
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: dialTimeout}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", fmt.Sprintf("%s:%d", host, port))
+	if err != nil {
+		return nil, interrupted(ctx, fmt.Errorf("Failed to dial telnet to %s:%d: %w", host, port, err))
+	}
+
+	// Closing the connection aborts the login when the run is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	tc := &telnetConn{conn: conn}
+	e := newShellExecutor(tc, tc, conn, timeout)
+	if err := telnetLogin(e, opts); err != nil {
+		conn.Close()
+		return nil, interrupted(ctx, err)
+	}
+	if err := e.prepare(pagerCommand); err != nil {
+		conn.Close()
+		return nil, interrupted(ctx, err)
+	}
+	return e, nil
+}
+
+// telnetLogin answers the username and password prompts until the device prompt is shown.
+func telnetLogin(e *ShellExecutor, opts AuthOptions) error {
+	sentPassword := false
+	for {
+		output, err := e.readUntil(func(output string) bool {
+			trimmed := strings.TrimRight(output, " \n")
+			return usernamePromptPattern.MatchString(trimmed) || passwordPromptPattern.MatchString(trimmed) || promptPattern.MatchString(trimmed)
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("telnet login failed: %v", err)
+		}
+		trimmed := strings.TrimRight(strings.ReplaceAll(output, "\r", ""), " \n")
+
+		switch {
+		case sentPassword && loginFailedPattern.MatchString(output):
+			return fmt.Errorf("telnet authentication failed for user %s", opts.Username)
+		case usernamePromptPattern.MatchString(trimmed):
+			e.stdin.Write([]byte(opts.Username + "\n"))
+		case passwordPromptPattern.MatchString(trimmed):
+			if sentPassword {
+				return fmt.Errorf("telnet authentication failed for user %s", opts.Username)
+			}
+			e.stdin.Write([]byte(opts.Password + "\n"))
+			sentPassword = true
+		default:
+			return nil // Device prompt
+		}
+	}
+}
+
+// telnetConn strips telnet option negotiation from the data read and answers it, and translates line endings on write.
+type telnetConn struct {
+	conn    net.Conn
+	pending []byte // Incomplete command sequence carried over to the next read
+}
+
+func (t *telnetConn) Read(p []byte) (int, error) {
+	for {
+		buf := make([]byte, max(len(p)-len(t.pending), 1)) // Leave room for the carried over bytes
+		n, err := t.conn.Read(buf)
+		data := append(t.pending, buf[:n]...)
+		t.pending = nil
+
+		out := 0
+		for i := 0; i < len(data); i++ {
+			if data[i] != telnetIAC {
+				p[out] = data[i]
+				out++
+				continue
+			}
+			if i+1 >= len(data) {
+				t.pending = data[i:]
+				break
+			}
+			switch command := data[i+1]; command {
+			case telnetIAC: // Escaped 0xFF data byte
+				p[out] = telnetIAC
+				out++
+				i++
+			case telnetDO, telnetDONT, telnetWILL, telnetWONT:
+				if i+2 >= len(data) {
+					t.pending = data[i:]
+					i = len(data)
+					continue
+				}
+				t.negotiate(command, data[i+2])
+				i += 2
+			case telnetSB: // Skip subnegotiation up to IAC SE
+				end := bytes.Index(data[i:], []byte{telnetIAC, telnetSE})
+				if end < 0 {
+					t.pending = data[i:]
+					i = len(data)
+					continue
+				}
+				i += end + 1
+			default:
+				i++ // Other two-byte commands (NOP, GA, ...)
+			}
+		}
+
+		if out > 0 || err != nil {
+			return out, err
+		}
+	}
+}
+
+// negotiate lets the device echo and suppress go-ahead, and refuses every other option.
+func (t *telnetConn) negotiate(command, option byte) {
+	var reply byte
+	switch command {
+	case telnetWILL:
+		reply = telnetDONT
+		if option == telnetOptionEcho || option == telnetOptionSGA {
+			reply = telnetDO
+		}
+	case telnetDO:
+		reply = telnetWONT
+	default:
+		return // DONT and WONT need no answer
+	}
+	t.conn.Write([]byte{telnetIAC, reply, option})
+}
+
+func (t *telnetConn) Write(p []byte) (int, error) {
+	data := bytes.ReplaceAll(p, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})
+	data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+	if _, err := t.conn.Write(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
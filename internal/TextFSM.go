@@ -0,0 +1,362 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+TextFSM is a parser for the template language of Google's TextFSM, as used by ntc-templates.
+
+A template declares the values to extract and a state machine of line rules:
+
+	Value PORT (\S+)
+	Value STATUS (up|down)
+
+	Start
+	  ^${PORT}\s+${STATUS} -> Record
+
+Supported: the Filldown, Fillup, Required, List and Key value options; the Next and Continue line actions; the Record,
+NoRecord, Clear and Clearall record actions; state transitions, the End and EOF states and the Error action.
+Templates use Go regular expression syntax, which accepts the Python syntax of most ntc-templates but not look-around
+assertions or back references; such templates are rejected when loaded.
+*/
+type TextFSM struct {
+	Name   string // Template name, used in error messages
+	values []*fsmValue
+	states map[string][]*fsmRule
+}
+
+type fsmValue struct {
+	name     string
+	pattern  string // Value regex with its outer parentheses turned into a named group
+	filldown bool
+	fillup   bool
+	required bool
+	list     bool
+}
+
+type fsmRule struct {
+	match       *regexp.Regexp
+	lineAction  string // Next or Continue
+	recordOp    string // Record, NoRecord, Clear or Clearall
+	newState    string
+	raiseError  bool
+	errorString string
+}
+
+// fsmRuleAction splits a rule into its regex and action at the last " -> " of the line; fsmRuleLine matches rules without action.
+var (
+	fsmRuleAction = regexp.MustCompile(`^\s+\^(.*)\s+->\s+(.*)$`)
+	fsmRuleLine   = regexp.MustCompile(`^\s+\^(.*)()$`)
+)
+
+// fsmVariable matches the ${NAME} and $NAME references to values in a rule regex.
+var fsmVariable = regexp.MustCompile(`\$\{(\w+)\}|\$(\w+)`)
+
+/*
+Parse a TextFSM template.
+
+Parameters:
+  - name string: The template name, used in error messages (e.g. its file name).
+  - template string: The template text.
+
+Returns:
+  - *TextFSM: The compiled template, safe for concurrent use.
+  - error: Returned if the template is malformed or uses a regex Go does not support.
+*/
+
+func ParseTextFSM(name, template string) (*TextFSM, error) {
+	fsm := &TextFSM{Name: name, states: make(map[string][]*fsmRule)}
+	scanner := bufio.NewScanner(strings.NewReader(template))
+	lineNumber := 0
+	fail := func(format string, args ...any) error {
+		return fmt.Errorf("template %s line %d: %s", name, lineNumber, fmt.Sprintf(format, args...))
+	}
+
+	// Value definitions, up to the first blank line
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if line == "" {
+			if len(fsm.values) == 0 {
+				continue
+			}
+			break
+		}
+		value, err := parseFSMValue(line)
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		fsm.values = append(fsm.values, value)
+	}
+	if len(fsm.values) == 0 {
+		return nil, fail("no Value definitions")
+	}
+
+	// States: a name on its own line followed by indented rules, ended by a blank line
+	state := ""
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(line), "#"):
+		case line == "":
+			state = ""
+		case state == "":
+			state = strings.TrimSpace(line)
+			if _, exists := fsm.states[state]; exists {
+				return nil, fail("duplicate state %q", state)
+			}
+			fsm.states[state] = nil
+		default:
+			rule, err := fsm.parseRule(line)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			fsm.states[state] = append(fsm.states[state], rule)
+		}
+	}
+	if _, ok := fsm.states["Start"]; !ok {
+		return nil, fail("missing Start state")
+	}
+	for name, rules := range fsm.states {
+		for _, rule := range rules {
+			if _, ok := fsm.states[rule.newState]; rule.newState != "" && !ok && rule.newState != "End" {
+				return nil, fmt.Errorf("template %s: state %s moves to unknown state %q", fsm.Name, name, rule.newState)
+			}
+		}
+	}
+	return fsm, nil
+}
+
+// parseFSMValue parses a "Value [Options] NAME (regex)" line.
+func parseFSMValue(line string) (*fsmValue, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "Value" {
+		return nil, fmt.Errorf("expected \"Value [Options] NAME (regex)\", got %q", line)
+	}
+	value := &fsmValue{}
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "Value"))
+	if !strings.HasPrefix(fields[2], "(") {
+		// Options come first
+		for _, option := range strings.Split(fields[1], ",") {
+			switch option {
+			case "Filldown":
+				value.filldown = true
+			case "Fillup":
+				value.fillup = true
+			case "Required":
+				value.required = true
+			case "List":
+				value.list = true
+			case "Key":
+			default:
+				return nil, fmt.Errorf("unknown value option %q", option)
+			}
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, fields[1]))
+	}
+	name, regex, _ := strings.Cut(rest, " ")
+	regex = strings.TrimSpace(regex)
+	if !strings.HasPrefix(regex, "(") || !strings.HasSuffix(regex, ")") {
+		return nil, fmt.Errorf("value %s: regex must be enclosed in parentheses", name)
+	}
+	value.name = name
+	value.pattern = "(?P<" + name + ">" + regex[1:]
+	if _, err := regexp.Compile(value.pattern); err != nil {
+		return nil, fmt.Errorf("value %s: %v", name, err)
+	}
+	return value, nil
+}
+
+// parseRule parses an indented "^regex [-> action]" rule, substituting the value references.
+func (fsm *TextFSM) parseRule(line string) (*fsmRule, error) {
+	parts := fsmRuleAction.FindStringSubmatch(line)
+	if parts == nil {
+		parts = fsmRuleLine.FindStringSubmatch(line)
+	}
+	if parts == nil {
+		return nil, fmt.Errorf("rules must be indented and start with ^, got %q", line)
+	}
+
+	var missing string
+	pattern := fsmVariable.ReplaceAllStringFunc("^"+strings.ReplaceAll(parts[1], "$$", "\x00"), func(ref string) string {
+		name := strings.Trim(ref, "${}")
+		for _, value := range fsm.values {
+			if value.name == name {
+				return value.pattern
+			}
+		}
+		missing = name
+		return ref
+	})
+	if missing != "" {
+		return nil, fmt.Errorf("unknown value %q", missing)
+	}
+	match, err := regexp.Compile(strings.ReplaceAll(pattern, "\x00", "$"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule regex: %v", err)
+	}
+
+	rule := &fsmRule{match: match, lineAction: "Next", recordOp: "NoRecord"}
+	action := strings.TrimSpace(parts[2])
+	if action == "" {
+		return rule, nil
+	}
+	if strings.HasPrefix(action, "Error") {
+		rule.raiseError = true
+		rule.errorString = strings.Trim(strings.TrimSpace(strings.TrimPrefix(action, "Error")), `"`)
+		return rule, nil
+	}
+	fields := strings.Fields(action)
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("invalid action %q", action)
+	}
+	operations := strings.Split(fields[0], ".")
+	known := true
+	for _, operation := range operations {
+		switch operation {
+		case "Next", "Continue":
+			rule.lineAction = operation
+		case "Record", "NoRecord", "Clear", "Clearall":
+			rule.recordOp = operation
+		default:
+			known = false
+		}
+	}
+	switch {
+	case known && len(fields) == 2:
+		rule.newState = fields[1]
+	case !known && len(fields) == 1 && len(operations) == 1:
+		rule.newState = fields[0] // Only a state transition
+	case !known:
+		return nil, fmt.Errorf("invalid action %q", action)
+	}
+	if rule.lineAction == "Continue" && rule.newState != "" {
+		return nil, fmt.Errorf("action %q cannot change state with Continue", action)
+	}
+	return rule, nil
+}
+
+// fsmRun holds the state of one Parse call, so a template can be used by several goroutines.
+type fsmRun struct {
+	fsm     *TextFSM
+	current []string   // Current value of each Value; List values are joined with spaces
+	records [][]string // Records produced so far
+}
+
+/*
+Run the template over a command output.
+
+Parameters:
+  - output string: The raw command output.
+
+Returns:
+  - []map[string]string: One record per Record action, keyed by value name.
+  - error: Returned if an Error action is reached.
+*/
+func (fsm *TextFSM) ParseText(output string) ([]map[string]string, error) {
+	run := &fsmRun{fsm: fsm, current: make([]string, len(fsm.values))}
+	state := "Start"
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+lines:
+	for scanner.Scan() && state != "End" {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		for _, rule := range fsm.states[state] {
+			matches := rule.match.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+			if rule.raiseError {
+				return nil, fmt.Errorf("template %s: error action %q on line %q", fsm.Name, rule.errorString, line)
+			}
+			run.assign(rule.match, matches)
+			switch rule.recordOp {
+			case "Record":
+				run.record()
+			case "Clear":
+				run.clear(false)
+			case "Clearall":
+				run.clear(true)
+			}
+			if rule.newState != "" {
+				state = rule.newState
+			}
+			if rule.lineAction == "Next" {
+				continue lines
+			}
+		}
+	}
+	if _, ok := fsm.states["EOF"]; !ok && state != "End" {
+		run.record() // Implicit Record at the end of the input unless an EOF state is declared
+	}
+
+	records := make([]map[string]string, len(run.records))
+	for i, values := range run.records {
+		records[i] = make(map[string]string, len(values))
+		for j, value := range fsm.values {
+			records[i][value.name] = values[j]
+		}
+	}
+	return records, nil
+}
+
+// assign stores the values captured by a rule.
+func (run *fsmRun) assign(match *regexp.Regexp, matches []string) {
+	for i, name := range match.SubexpNames() {
+		if name == "" || i >= len(matches) {
+			continue
+		}
+		for j, value := range run.fsm.values {
+			if value.name != name {
+				continue
+			}
+			captured := matches[i]
+			if value.list && run.current[j] != "" && captured != "" {
+				captured = run.current[j] + " " + captured
+			}
+			run.current[j] = captured
+			if value.fillup && captured != "" {
+				// Fill the same value of the previous records upwards until one already has it
+				for k := len(run.records) - 1; k >= 0 && run.records[k][j] == ""; k-- {
+					run.records[k][j] = captured
+				}
+			}
+		}
+	}
+}
+
+// record appends the current values as a record, unless they are all empty or a Required value is missing.
+func (run *fsmRun) record() {
+	empty := true
+	for j, value := range run.fsm.values {
+		if value.required && run.current[j] == "" {
+			run.clear(false)
+			return
+		}
+		if run.current[j] != "" {
+			empty = false
+		}
+	}
+	if !empty {
+		run.records = append(run.records, append([]string(nil), run.current...))
+	}
+	run.clear(false)
+}
+
+// clear resets the current values, keeping Filldown values unless all is set.
+func (run *fsmRun) clear(all bool) {
+	for j, value := range run.fsm.values {
+		if all || !value.filldown {
+			run.current[j] = ""
+		}
+	}
+}
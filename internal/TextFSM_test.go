@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseTemplate runs a template over an output, failing the test if either is rejected.
+func parseTemplate(t *testing.T, template, output string) []map[string]string {
+	t.Helper()
+	fsm, err := ParseTextFSM("test", template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := fsm.ParseText(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestTextFSMValueOptions(t *testing.T) {
+	template := `Value Filldown CHASSIS (\S+)
+Value Required PORT (\S+)
+Value List VLANS (\d+)
+
+Start
+  ^Chassis ${CHASSIS}
+  ^Port ${PORT}
+  ^\s+vlan ${VLANS}
+  ^End -> Record
+`
+	output := `Chassis A
+Port p1
+  vlan 10
+  vlan 20
+End
+Port p2
+End
+End
+Chassis B
+Port p3
+End
+`
+	// Filldown keeps the chassis across records, List collects every VLAN, and the record without PORT is dropped
+	want := []map[string]string{
+		{"CHASSIS": "A", "PORT": "p1", "VLANS": "10 20"},
+		{"CHASSIS": "A", "PORT": "p2", "VLANS": ""},
+		{"CHASSIS": "B", "PORT": "p3", "VLANS": ""},
+	}
+	if records := parseTemplate(t, template, output); !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+}
+
+func TestTextFSMFillup(t *testing.T) {
+	template := `Value PORT (Gi\S+)
+Value Fillup VRF (\S+)
+
+Start
+  ^${PORT}$$ -> Record
+  ^vrf ${VRF}$$
+
+EOF
+`
+	// The VRF found after the ports fills the records above it
+	records := parseTemplate(t, template, "Gi1\nGi2\nvrf blue\n")
+	want := []map[string]string{{"PORT": "Gi1", "VRF": "blue"}, {"PORT": "Gi2", "VRF": "blue"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+}
+
+func TestTextFSMContinue(t *testing.T) {
+	template := `Value PORT (\S+)
+Value SPEED (\d+)
+
+Start
+  ^${PORT} -> Continue
+  ^\S+ speed ${SPEED} -> Record
+`
+	records := parseTemplate(t, template, "Gi1 speed 1000\nGi2 speed 10\n")
+	want := []map[string]string{{"PORT": "Gi1", "SPEED": "1000"}, {"PORT": "Gi2", "SPEED": "10"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+}
+
+func TestTextFSMStateTransitions(t *testing.T) {
+	template := `Value PORT (\S+)
+
+Start
+  ^Ports: -> Ports
+
+Ports
+  ^Done -> End
+  ^${PORT}$$ -> Record
+`
+	// Lines before the Ports state and after End are not parsed
+	records := parseTemplate(t, template, "Gi0\nPorts:\nGi1\nGi2\nDone\nGi3\n")
+	want := []map[string]string{{"PORT": "Gi1"}, {"PORT": "Gi2"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+}
+
+func TestTextFSMEOF(t *testing.T) {
+	values := "Value PORT (\\S+)\n\nStart\n  ^${PORT}$$\n"
+	// The values left at the end of the input are recorded, unless an EOF state is declared
+	if records := parseTemplate(t, values, "Gi1\nGi2\n"); !reflect.DeepEqual(records, []map[string]string{{"PORT": "Gi2"}}) {
+		t.Errorf("implicit EOF records = %v, want [map[PORT:Gi2]]", records)
+	}
+	if records := parseTemplate(t, values+"\nEOF\n", "Gi1\nGi2\n"); len(records) != 0 {
+		t.Errorf("explicit EOF records = %v, want none", records)
+	}
+}
+
+func TestTextFSMError(t *testing.T) {
+	fsm, err := ParseTextFSM("test", "Value PORT (\\S+)\n\nStart\n  ^${PORT}$$ -> Record\n  ^. -> Error \"unexpected line\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsm.ParseText("Gi1\nnot a port\n"); err == nil || !strings.Contains(err.Error(), "unexpected line") {
+		t.Errorf("error = %v, want the Error action message", err)
+	}
+}
+
+func TestParseTextFSMRejectsInvalidTemplates(t *testing.T) {
+	templates := map[string]string{
+		"no values":             "Start\n  ^x\n",
+		"no Start state":        "Value PORT (\\S+)\n\nPorts\n  ^${PORT}\n",
+		"unknown value":         "Value PORT (\\S+)\n\nStart\n  ^${SPEED}\n",
+		"unknown state":         "Value PORT (\\S+)\n\nStart\n  ^${PORT} -> Ports\n",
+		"Continue with state":   "Value PORT (\\S+)\n\nStart\n  ^${PORT} -> Continue Start\n",
+		"unknown option":        "Value Sticky PORT (\\S+)\n\nStart\n  ^${PORT}\n",
+		"look-around assertion": "Value PORT ((?=G)\\S+)\n\nStart\n  ^${PORT}\n",
+	}
+	for name, template := range templates {
+		if _, err := ParseTextFSM(name, template); err == nil {
+			t.Errorf("%s: template accepted", name)
+		}
+	}
+}
+
+func TestCommandPatternExpandsCompletions(t *testing.T) {
+	pattern, err := commandPattern("sh[[ow]] int[[erfaces]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{"sh int", "sho int", "show int", "show inter", "show interfaces", "show interfaces status"} {
+		if !pattern.MatchString(command) {
+			t.Errorf("%q does not match", command)
+		}
+	}
+	for _, command := range []string{"s int", "show ip int", "showint", "sh in", "display interfaces"} {
+		if pattern.MatchString(command) {
+			t.Errorf("%q matches", command)
+		}
+	}
+}
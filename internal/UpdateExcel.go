@@ -5,24 +5,24 @@ import (
 	"github.com/pterm/pterm"
 	"github.com/tealeg/xlsx"
 	"log"
-	"time"
 )
 
-// Open an existing Excel file, add a new sheet with current date and time, and populate it with data.
-func UpdateExcel(data []InterfaceData, filename string, logger *pterm.Logger) error {
+// Open an existing Excel file, add the audit sheet of the run (see AuditSheetName), and populate it with data.
+func UpdateExcel(data []InterfaceData, filename, runID string, logger *pterm.Logger) error {
 	// Open the existing Excel file.
 	file, err := xlsx.OpenFile(filename)
 	if err != nil {
 		return err // Return the error if the file cannot be opened.
 	}
 
-	// Format the current date and time as 'DDMMYY_HHMM' and create a sheet name with it.
-	dateTime := time.Now().Format("02012006") // Format: DDMMYYYY
-	sheetName := fmt.Sprintf("Audit %s", dateTime)
+	// Name the sheet after the run ID, unique for every run
+	sheetName := AuditSheetName(runID)
+	if file.Sheet[sheetName] != nil {
+		return fmt.Errorf("sheet '%s' already exists: run %s was already saved to %s", sheetName, runID, filename)
+	}
 	sheet, err := file.AddSheet(sheetName)
 	if err != nil {
-		logger.Fatal("Failed to add sheet", logger.Args("Reason", err))
-		log.Fatalf("Failed to add sheet: %v", err) // Log and return the error if a new sheet cannot be added.
+		log.Printf("Failed to add sheet: %v", err) // Log and return the error if a new sheet cannot be added.
 		return err
 	}
 
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Findings that are not a field difference, matched by Waiver.Field.
+const (
+	FindingNew     = "new"     // Port not in the reference
+	FindingMissing = "missing" // Reference port missing from the device
+)
+
+/*
+Waiver suppresses the findings of the comparison it matches until its expiry date. Every match key that is set must
+match; a waiver without Field applies to every finding of the matched ports.
+*/
+type Waiver struct {
+	Node        string `yaml:"node"`        // Glob on the node name, e.g. "sw-lab-*"
+	Interface   string `yaml:"interface"`   // Regex on the interface name, long (GigabitEthernet1/0/1) or short (Gi1/0/1)
+	Description string `yaml:"description"` // Regex on the reference or the new description of the port
+	Field       string `yaml:"field"`       // Compared field (see -compare-fields), "new" or "missing"
+	Owner       string `yaml:"owner"`
+	Reason      string `yaml:"reason"`
+	Ticket      string `yaml:"ticket"`
+	Expires     string `yaml:"expires"` // Last day the waiver applies, YYYY-MM-DD
+
+	interfacePattern   *regexp.Regexp
+	descriptionPattern *regexp.Regexp
+	expires            time.Time // Start of the day after Expires, when the waiver stops applying
+}
+
+// waiversFile is the layout of the waivers file.
+type waiversFile struct {
+	Waivers []*Waiver `yaml:"waivers"`
+}
+
+/*
+Load the waivers of the comparison from a YAML file.
+
+Parameters:
+  - path string: The waivers file, holding a "waivers" list.
+
+Returns:
+  - []*Waiver: The waivers, in file order; the first matching waiver applies to a finding.
+  - error: Returned if the file cannot be read, or a waiver has no match key, lacks its owner, reason, ticket or
+    expiry date, or has an invalid glob, regex, field or date.
+*/
+
+func LoadWaivers(path string) ([]*Waiver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read waivers file: %v", err)
+	}
+	var file waiversFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal waivers file: %v", err)
+	}
+
+	for i, waiver := range file.Waivers {
+		if waiver == nil {
+			return nil, fmt.Errorf("waiver %d is empty", i+1)
+		}
+		if err := waiver.prepare(); err != nil {
+			return nil, fmt.Errorf("waiver %d: %v", i+1, err)
+		}
+		if waiver.expired(time.Now()) {
+			log.Printf("Waiver %d (%s, ticket %s) expired on %s: its findings are reported again", i+1, waiver.Owner, waiver.Ticket, waiver.Expires)
+		}
+	}
+	log.Printf("Loaded %d waivers from %s", len(file.Waivers), path)
+	return file.Waivers, nil
+}
+
+// prepare checks a waiver and compiles its patterns.
+func (w *Waiver) prepare() error {
+	if w.Node == "" && w.Interface == "" && w.Description == "" && w.Field == "" {
+		return fmt.Errorf("at least one of node, interface, description or field is required")
+	}
+	if w.Owner == "" || w.Reason == "" || w.Ticket == "" || w.Expires == "" {
+		return fmt.Errorf("owner, reason, ticket and expires are required")
+	}
+	if _, err := path.Match(w.Node, ""); err != nil {
+		return fmt.Errorf("invalid node glob %q: %v", w.Node, err)
+	}
+	var err error
+	if w.Interface != "" {
+		if w.interfacePattern, err = regexp.Compile(w.Interface); err != nil {
+			return fmt.Errorf("invalid interface regex: %v", err)
+		}
+	}
+	if w.Description != "" {
+		if w.descriptionPattern, err = regexp.Compile(w.Description); err != nil {
+			return fmt.Errorf("invalid description regex: %v", err)
+		}
+	}
+	w.Field = strings.ToLower(strings.TrimSpace(w.Field))
+	if _, ok := compareFieldNames[w.Field]; !ok && w.Field != "" && w.Field != FindingNew && w.Field != FindingMissing {
+		return fmt.Errorf("unknown field %q (supported: %s, %s, %s)", w.Field, joinSorted(compareFieldNames), FindingNew, FindingMissing)
+	}
+	day, err := time.ParseInLocation("2006-01-02", w.Expires, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid expiry date %q, expected YYYY-MM-DD", w.Expires)
+	}
+	w.expires = day.AddDate(0, 0, 1)
+	return nil
+}
+
+// matches reports whether the waiver covers a finding of a port; field is the compared field, "new" or "missing".
+func (w *Waiver) matches(node, interfaceName, refDescription, newDescription, field string) bool {
+	if w.Node != "" {
+		if ok, _ := path.Match(w.Node, node); !ok {
+			return false
+		}
+	}
+	if w.interfacePattern != nil {
+		name := ParseInterfaceName(interfaceName)
+		if !w.interfacePattern.MatchString(name.Long()) && !w.interfacePattern.MatchString(name.Short()) {
+			return false
+		}
+	}
+	if w.descriptionPattern != nil && !w.descriptionPattern.MatchString(refDescription) && !w.descriptionPattern.MatchString(newDescription) {
+		return false
+	}
+	return w.Field == "" || w.Field == field
+}
+
+// expired reports whether the expiry date of the waiver has passed.
+func (w *Waiver) expired(now time.Time) bool {
+	return !now.Before(w.expires)
+}
+
+// String describes the waiver for the reports.
+func (w *Waiver) String() string {
+	return fmt.Sprintf("owner %s, ticket %s, expires %s: %s", w.Owner, w.Ticket, w.Expires, w.Reason)
+}
+
+/*
+Find the waiver of a finding.
+
+Returns:
+  - *Waiver: The first active waiver matching the finding, nil if none.
+  - *Waiver: The first expired waiver matching the finding when no active one does, so the finding can mention it.
+*/
+func findWaiver(waivers []*Waiver, now time.Time, node, interfaceName, refDescription, newDescription, field string) (*Waiver, *Waiver) {
+	var expired *Waiver
+	for _, waiver := range waivers {
+		if !waiver.matches(node, interfaceName, refDescription, newDescription, field) {
+			continue
+		}
+		if !waiver.expired(now) {
+			return waiver, nil
+		}
+		if expired == nil {
+			expired = waiver
+		}
+	}
+	return nil, expired
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// unsafeFileChars matches the characters replaced in raw output file names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// RawOutputFileName returns the file holding the raw output of a command run on a host: "<host>__<command>.txt",
+// with the spaces of the command replaced by underscores (e.g. "sw1__show_interface_status.txt").
+func RawOutputFileName(host, command string) string {
+	return fmt.Sprintf("%s__%s.txt", unsafeFileChars.ReplaceAllString(host, "_"), unsafeFileChars.ReplaceAllString(command, "_"))
+}
+
+/*
+Save the raw output of every command run on a device, and a report of the interface-like lines its parsers missed.
+
+Parameters:
+  - dir string: The raw output directory of the run, created if needed.
+  - result DeviceResult: The result of the device, holding the output of each command of its last attempt.
+
+Returns:
+  - []string: The files written, the only files of the directory archived and deleted with the reports.
+  - int: The number of unparsed interface-like lines written to the device's "unparsed_report_<host>.txt".
+  - error: Returned if the directory or a file cannot be written.
+*/
+
+func WriteRawOutput(dir string, result DeviceResult) ([]string, int, error) {
+	if len(result.Outputs) == 0 {
+		return nil, 0, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create raw output directory %s: %v", dir, err)
+	}
+
+	var written []string
+	var report strings.Builder
+	unparsed := 0
+	for _, output := range result.Outputs {
+		path := filepath.Join(dir, RawOutputFileName(result.Device.Host, output.Command))
+		if err := os.WriteFile(path, []byte(output.Output), 0644); err != nil {
+			return written, unparsed, fmt.Errorf("failed to write raw output %s: %v", path, err)
+		}
+		written = append(written, path)
+
+		parser, ok := LookupParser(result.Device.Platform, output.Command)
+		if output.Err != nil || !ok {
+			continue // Nothing was parsed from this output
+		}
+		lines := UnparsedInterfaceLines(output.Output, ProcessOutput(output.Output, parser, result.Device))
+		if len(lines) == 0 {
+			continue
+		}
+		unparsed += len(lines)
+		report.WriteString(fmt.Sprintf("Command: %s (%d unparsed lines)\n", output.Command, len(lines)))
+		for _, line := range lines {
+			report.WriteString(line + "\n")
+		}
+		report.WriteString("-----------------------------------\n")
+	}
+
+	if unparsed > 0 {
+		path := filepath.Join(dir, fmt.Sprintf("unparsed_report_%s.txt", unsafeFileChars.ReplaceAllString(result.Device.Host, "_")))
+		header := fmt.Sprintf("Unparsed Report for %s: interface-like lines not matched by the parsers\n===================================\n", result.Device.Host)
+		if err := os.WriteFile(path, []byte(header+report.String()), 0644); err != nil {
+			return written, unparsed, fmt.Errorf("failed to write unparsed report %s: %v", path, err)
+		}
+		written = append(written, path)
+		log.Printf("%d interface-like lines from %s were not parsed, see %s", unparsed, result.Device.Host, path)
+	}
+	return written, unparsed, nil
+}
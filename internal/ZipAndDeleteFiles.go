@@ -9,15 +9,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 // Create a zip archive containing all files with "audit_report" in their name located in the working directory,
-// then delete the original files after successful zipping.
-func ZipAndDeleteFiles(srcDir string, logger *pterm.Logger) (string, error) {
-	date := time.Now().Format("02-01-2006")           // Current date
-	zipFileName := fmt.Sprintf("report_%s.zip", date) // Name of the zip file
-	zipFilePath := filepath.Join(srcDir, zipFileName) // Full path to the new zip file
+// and the raw output files written by this run (rawFiles, see WriteRawOutput, stored under the name of rawDir), then
+// delete the original files after successful zipping. Other files of the raw output directory are left untouched.
+// The archive is named after the run ID, so the runs of one day keep their own archive.
+func ZipAndDeleteFiles(srcDir, rawDir string, rawFiles []string, runID string, logger *pterm.Logger) (string, error) {
+	zipFileName := fmt.Sprintf("report_%s.zip", runID) // Name of the zip file
+	zipFilePath := filepath.Join(srcDir, zipFileName)  // Full path to the new zip file
 
 	// Create the zip file
 	newZipFile, err := os.Create(zipFilePath)
@@ -67,6 +67,18 @@ func ZipAndDeleteFiles(srcDir string, logger *pterm.Logger) (string, error) {
 		return "", fmt.Errorf("failed to add files to zip: %v", err)
 	}
 
+	// Add the raw device output of the run under the name of its directory
+	rawEntryDir := filepath.Base(filepath.Clean(rawDir))
+	if rawEntryDir == "." || rawEntryDir == string(filepath.Separator) {
+		rawEntryDir = "raw_output"
+	}
+	for _, filePath := range rawFiles {
+		if err := addFileToZip(zipWriter, filePath, filepath.Join(rawEntryDir, filepath.Base(filePath))); err != nil {
+			return "", fmt.Errorf("failed to add raw output to zip: %v", err)
+		}
+		filesToDelete = append(filesToDelete, filePath)
+	}
+
 	// Delete the original files after successful zipping
 	for _, file := range filesToDelete {
 		if err := os.Remove(file); err != nil {
@@ -76,7 +88,29 @@ func ZipAndDeleteFiles(srcDir string, logger *pterm.Logger) (string, error) {
 		}
 	}
 
+	if len(rawFiles) > 0 {
+		os.Remove(rawDir) // Only removed once empty
+	}
+
 	log.Printf("Successfully created and cleaned up zip archive: %s", zipFilePath)
 	//logger.Info("Difference reports have been compiled, archived and available for download.", logger.Args("file", zipFilePath)) // Log to the screen
 	return zipFilePath, nil
 }
+
+// addFileToZip copies a file into a new entry of the zip archive.
+func addFileToZip(zipWriter *zip.Writer, filePath, entryName string) error {
+	fileToZip, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %v", filePath, err)
+	}
+	defer fileToZip.Close()
+
+	zipEntry, err := zipWriter.Create(filepath.ToSlash(entryName))
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry for file %s: %v", filePath, err)
+	}
+	if _, err := io.Copy(zipEntry, fileToZip); err != nil {
+		return fmt.Errorf("failed to write file %s to zip: %v", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestZipAndDeleteFilesArchivesOnlyFilesOfTheRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// The raw output directory is the working directory, which also holds the workbook and an earlier capture
+	write("PortAudit.xlsx")
+	write("sw0__show_interface_status.txt")
+	write("audit_report_sw1_18-10-2026 09:00:00.txt")
+	result := DeviceResult{
+		Device:  Device{Host: "sw1", Platform: "ios"},
+		Outputs: []CommandOutput{{Command: "show version", Output: "Cisco IOS Software\n"}},
+	}
+	rawFiles, _, err := WriteRawOutput(dir, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath, err := ZipAndDeleteFiles(dir, dir, rawFiles, "18102026-090000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	var entries []string
+	for _, file := range archive.File {
+		entries = append(entries, file.Name)
+	}
+	sort.Strings(entries)
+	want := []string{"audit_report_sw1_18-10-2026 09:00:00.txt", filepath.Base(dir) + "/sw1__show_version.txt"}
+	sort.Strings(want)
+	if len(entries) != len(want) || entries[0] != want[0] || entries[1] != want[1] {
+		t.Errorf("archive entries = %q, want %q", entries, want)
+	}
+	for _, name := range []string{"PortAudit.xlsx", "sw0__show_interface_status.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was deleted: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(rawFiles[0]); !os.IsNotExist(err) {
+		t.Errorf("raw output of the run was not deleted: %v", err)
+	}
+}